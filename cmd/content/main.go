@@ -3,11 +3,15 @@ package main
 import (
 	"bytes"
 	"context"
+	"expvar"
+	"flag"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -17,18 +21,54 @@ import (
 	"github.com/ice-cream-psychics-club/dropbox/internal/pkg/subscriber"
 	"github.com/ice-cream-psychics-club/dropbox/pkg/csv"
 	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/store"
 )
 
 var developmentTimeout = 15 * time.Minute
 
+const (
+	webhookMode  = "webhook"
+	longpollMode = "longpoll"
+
+	// longpolling doesn't tell us which account changed; this stands in when
+	// the account can't be looked up
+	longpollAccount = "longpoll"
+
+	// warn when more than this fraction of the account's space is used
+	spaceUsageWarning = 0.9
+
+	metadataCacheTTL = 30 * time.Second
+)
+
 func main() {
+	mode := flag.String("mode", webhookMode, "how to receive Dropbox changes: "+webhookMode+" or "+longpollMode)
+	flag.Parse()
+	if *mode != webhookMode && *mode != longpollMode {
+		panic(fmt.Errorf("unknown mode %s", *mode))
+	}
+
 	var (
 		clientID     = getEnvOrElse("DROPBOX_ACCESS_KEY")
 		clientSecret = getEnvOrElse("DROPBOX_ACCESS_SECRET")
 		host         = getEnvOrElse("HOST")
 		port         = getEnvOrElse("PORT")
 		redirectURL  = "http://" + host + ":" + port + "/oauth2/callback"
+		// admin routes are disabled without a token
+		adminToken = os.Getenv("ADMIN_TOKEN")
+		// requests to Dropbox are recorded here, if it's set
+		recordDir = os.Getenv("DROPBOX_RECORD_DIR")
+		// the app folder is mirrored here, if it's set
+		mirrorDir = os.Getenv("MIRROR_DIR")
+		// "team" for the team space, a namespace ID, or empty for the
+		// user's home
+		pathRoot = os.Getenv("DROPBOX_PATH_ROOT")
+		// what relative paths are under, within the path root; defaults to
+		// the app folder, which is only in the user's home
+		rootFolder = os.Getenv("DROPBOX_ROOT_FOLDER")
 	)
+	if pathRoot != "" && rootFolder == "" {
+		panic(fmt.Errorf("DROPBOX_ROOT_FOLDER is required with DROPBOX_PATH_ROOT"))
+	}
 
 	// setup dependencies
 	ctx, cancel := context.WithTimeout(context.Background(), developmentTimeout)
@@ -46,46 +86,64 @@ func main() {
 
 	dbx := api.NewDropbox(clientSecret, logger)
 
+	metrics := &dropbox.Metrics{}
+	expvar.Publish("dropbox", metrics)
+
 	// build subscribers
 	debugger := &subscriber.Logger{
 		Logger: logger,
 	}
-	convertSubmissionsToCSV := &subscriber.Propagator{
-		Source: "responses.xlsx",
-		Targets: []subscriber.Target{
-			{
-				Name: "submissions.csv",
-				Transform: func(r io.Reader) (io.Reader, error) {
-					// TODO: add transformations
-					filePath := "./tmp/responses.xlsx"
-					return xlsxToCSV(filePath, r)
-				},
+	submissionsToCSV := []subscriber.Target{
+		{
+			Name: "submissions.csv",
+			Transform: func(ctx context.Context, r io.Reader) (io.Reader, error) {
+				// TODO: add transformations
+				filePath := "./tmp/responses.xlsx"
+				return xlsxToCSV(filePath, r)
 			},
 		},
-		Logger: logger,
+	}
+	convertSubmissionsToCSV := &subscriber.Propagator{
+		Source:  "responses.xlsx",
+		Targets: submissionsToCSV,
+		Logger:  logger,
+	}
+	// form results kept as a Google Sheet show up in Dropbox as a .gsheet,
+	// which converts like an uploaded workbook once it's exported as one
+	convertSheetSubmissionsToCSV := &subscriber.Propagator{
+		Source:       "responses.gsheet",
+		Targets:      submissionsToCSV,
+		Logger:       logger,
+		ExportFormat: "xlsx",
 	}
 	parseSubmissions := &subscriber.Propagator{
 		Source: "ratings.csv",
 		Targets: []subscriber.Target{
 			{
 				Name: "submissions.csv",
-				Transform: func(r io.Reader) (io.Reader, error) {
+				Transform: func(ctx context.Context, r io.Reader) (io.Reader, error) {
 					// import current ratings
-					ratingsReader, err := dbx.Client.Download("ratings.csv")
+					ratingsFile, err := dbx.Client.DownloadContext(ctx, "ratings.csv")
 					if err != nil {
 						return nil, fmt.Errorf("error downloading ratings: %w", err)
 					}
-					ratings, members, err := content.ImportRatings(ratingsReader)
+					defer ratingsFile.Body.Close()
+					logger.Info("importing ratings from rev " + ratingsFile.File.Rev)
+
+					ratings, members, err := content.ImportRatings(ratingsFile.Body)
 					if err != nil {
 						return nil, fmt.Errorf("error importing ratings: %w", err)
 					}
 
 					// import previous submissions
-					prevReader, err := dbx.Client.Download("prev_responses.csv")
+					prevFile, err := dbx.Client.DownloadContext(ctx, "prev_responses.csv")
 					if err != nil {
 						return nil, fmt.Errorf("error downloading previous responses: %w", err)
 					}
-					prev, err := content.ImportSubmissions(prevReader)
+					defer prevFile.Body.Close()
+					logger.Info("importing previous responses from rev " + prevFile.File.Rev)
+
+					prev, err := content.ImportSubmissions(prevFile.Body)
 					if err != nil {
 						return nil, fmt.Errorf("error importing previous submissions: %w", err)
 					}
@@ -126,13 +184,22 @@ func main() {
 				},
 			},
 		},
+		Logger: logger,
 	}
 
 	// start server
-	router := newRouter(dbx, oauth2)
+	router := newRouter(dbx, oauth2, *mode, adminToken, logger)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: api.LogRequests(logger, router),
+		// cancel in-flight requests (and their subscribers) on shutdown
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
 	shutdown := make(chan error)
 	go func() {
-		if err := http.ListenAndServe(":"+port, api.LogRequests(logger, router)); err != nil {
+		if err := server.ListenAndServe(); err != nil {
 			shutdown <- err
 		}
 	}()
@@ -140,17 +207,76 @@ func main() {
 	logger.Debug("client initialized")
 
 	authClient := <-oauth2.Client()
-	client := &dropbox.Client{
+	dbxClient := &dropbox.Client{
 		HTTPClient: authClient,
 		Logger:     logger,
+		RootFolder: rootFolder,
+	}
+	dbxClient.Use(
+		dropbox.RequestIDs(),
+		dropbox.LogRequests(logger),
+	)
+	if recordDir != "" {
+		dbxClient.Use(dropbox.RecordRequests(recordDir))
+	}
+
+	var client dropbox.Files = dbxClient
+	client = dropbox.NewMetricsFiles(client, metrics)
+	client = dropbox.NewLoggingFiles(client, logger)
+	client = &dropbox.CachingFiles{Files: client, TTL: metadataCacheTTL}
+
+	account := longpollAccount
+	a, err := describeAccount(ctx, client, logger)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error describing account: %v", err))
+	} else {
+		account = a.AccountID
+	}
+
+	// the path root has to be chosen before the subscribers use the client
+	switch pathRoot {
+	case "":
+	case "team":
+		if a == nil {
+			panic(fmt.Errorf("can't find the team space without the account"))
+		}
+		root := dropbox.TeamPathRoot(a)
+		dbxClient.PathRoot = &root
+	default:
+		root := dropbox.PathRootNamespace(pathRoot)
+		dbxClient.PathRoot = &root
 	}
 
 	convertSubmissionsToCSV.Client = client
-	dbx.Subscribe(debugger, convertSubmissionsToCSV, parseSubmissions)
+	convertSheetSubmissionsToCSV.Client = client
+	parseSubmissions.Client = client
+	dbx.Subscribe(debugger, convertSubmissionsToCSV, convertSheetSubmissionsToCSV, parseSubmissions)
+
+	if mirrorDir != "" {
+		cursors, err := store.NewFileStore(filepath.Clean(mirrorDir) + ".cursor.json")
+		if err != nil {
+			panic(err)
+		}
+
+		dbx.Subscribe(&subscriber.Mirror{
+			Dir:     mirrorDir,
+			Client:  client,
+			Logger:  logger,
+			Cursors: cursors,
+		})
+	}
 	dbx.SetClient(client)
 
 	logger.Debug("ready to make dropbox requests")
 
+	if *mode == longpollMode {
+		go func() {
+			if err := dbx.Watch(ctx, account); err != nil {
+				shutdown <- fmt.Errorf("error watching for changes: %w", err)
+			}
+		}()
+	}
+
 	// handle shutdown
 	select {
 	case err := <-shutdown:
@@ -160,20 +286,58 @@ func main() {
 	}
 }
 
-func newRouter(dbx *api.Dropbox, oauth2 *api.OAuth2) *mux.Router {
+func newRouter(dbx *api.Dropbox, oauth2 *api.OAuth2, mode, adminToken string, logger *slog.Logger) *mux.Router {
 	base := mux.NewRouter()
 	base.HandleFunc("/", oauth2.AuthorizeHandle)
 	base.HandleFunc("/oauth2/callback", oauth2.ExchangeHandle)
 
-	dropbox := base.Path("dropbox").Subrouter()
+	dropbox := base.PathPrefix("/dropbox").Subrouter()
 	dropbox.HandleFunc("/file", dbx.DescribeFile).Methods("GET")
 	dropbox.HandleFunc("/folder", dbx.DescribeFolder).Methods("GET")
-	dropbox.HandleFunc("/update", dbx.VerifyWebhook).Methods("GET")
-	dropbox.HandleFunc("/update", dbx.ReceiveUpdate).Methods("POST")
+	dropbox.HandleFunc("/search", dbx.Search).Methods("GET")
+	dropbox.HandleFunc("/account", dbx.DescribeAccount).Methods("GET")
+	if mode == webhookMode {
+		dropbox.HandleFunc("/update", dbx.VerifyWebhook).Methods("GET")
+		dropbox.HandleFunc("/update", dbx.ReceiveUpdate).Methods("POST")
+	}
+
+	if adminToken != "" {
+		dropbox.Handle("/revisions", api.RequireToken(logger, adminToken, http.HandlerFunc(dbx.ListRevisions))).Methods("GET")
+		dropbox.Handle("/restore", api.RequireToken(logger, adminToken, http.HandlerFunc(dbx.Restore))).Methods("POST")
+		base.Handle("/debug/vars", api.RequireToken(logger, adminToken, expvar.Handler())).Methods("GET")
+	}
 
 	return base
 }
 
+// describeAccount logs which account the client is authorized for and warns
+// when it's running out of space.
+func describeAccount(ctx context.Context, client dropbox.Files, logger *slog.Logger) (*dropbox.FullAccount, error) {
+	account, err := client.GetCurrentAccountContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("authorized for %s (%s, %s)",
+		account.Name.DisplayName, account.Email, account.AccountID,
+	))
+
+	usage, err := client.GetSpaceUsageContext(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error getting space usage: %v", err))
+		return account, nil
+	}
+
+	used := usage.UsedFraction()
+	msg := fmt.Sprintf("using %.1f%% of allocated space (%d bytes)", used*100, usage.Used)
+	if used > spaceUsageWarning {
+		logger.Warn(msg)
+	} else {
+		logger.Info(msg)
+	}
+
+	return account, nil
+}
+
 func getEnvOrElse(k string) string {
 	v := os.Getenv(k)
 	if v == "" {
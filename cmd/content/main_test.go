@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/ice-cream-psychics-club/dropbox/internal/pkg/api"
+)
+
+func TestRoutes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		mode   string
+		method string
+		target string
+		want   bool
+	}{
+		{webhookMode, "GET", "/dropbox/file?path=a", true},
+		{webhookMode, "GET", "/dropbox/folder?path=a", true},
+		{webhookMode, "GET", "/dropbox/search?query=a", true},
+		{webhookMode, "GET", "/dropbox/account", true},
+		{webhookMode, "GET", "/dropbox/update?challenge=a", true},
+		{webhookMode, "POST", "/dropbox/update", true},
+		{webhookMode, "GET", "/dropbox/revisions?path=a", true},
+		{webhookMode, "POST", "/dropbox/restore", true},
+		{webhookMode, "GET", "/debug/vars", true},
+		{longpollMode, "GET", "/dropbox/file?path=a", true},
+		{longpollMode, "POST", "/dropbox/update", false},
+		{webhookMode, "GET", "/dropbox", false},
+	}
+	for _, tt := range tests {
+		router := newRouter(&api.Dropbox{}, &api.OAuth2{}, tt.mode, "admin", logger)
+
+		var match mux.RouteMatch
+		got := router.Match(httptest.NewRequest(tt.method, tt.target, nil), &match) && match.MatchErr == nil
+		if got != tt.want {
+			t.Errorf("%s %s in %s mode: matched = %v, want %v", tt.method, tt.target, tt.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Command mirror keeps a read-only local copy of a Dropbox folder, using a
+// static access token from DROPBOX_ACCESS_TOKEN.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"golang.org/x/oauth2"
+
+	"github.com/ice-cream-psychics-club/dropbox/internal/pkg/subscriber"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/store"
+)
+
+func main() {
+	var (
+		dir        = flag.String("dir", "mirror", "local directory to mirror into")
+		folder     = flag.String("folder", "", "Dropbox folder to mirror, relative to -root")
+		rootFolder = flag.String("root", "", "Dropbox folder -folder is relative to, within -namespace; defaults to "+dropbox.DefaultRootFolder)
+		state      = flag.String("state", "mirror.json", "file to keep the mirror's cursor in, outside -dir")
+		watch      = flag.Bool("watch", false, "keep syncing as the folder changes")
+		ns         = flag.String("namespace", "", "namespace ID to mirror from, e.g. a team folder's, instead of the user's home")
+	)
+	flag.Parse()
+
+	// the default root is the app folder, which is only in the user's home
+	if *ns != "" && *rootFolder == "" {
+		panic(fmt.Errorf("-root is required with -namespace"))
+	}
+
+	token := os.Getenv("DROPBOX_ACCESS_TOKEN")
+	if token == "" {
+		panic(fmt.Errorf("missing DROPBOX_ACCESS_TOKEN"))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+
+	cursors, err := store.NewFileStore(*state)
+	if err != nil {
+		panic(err)
+	}
+
+	client := &dropbox.Client{
+		HTTPClient: oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{
+			AccessToken: token,
+		})),
+		Logger:     logger,
+		RootFolder: *rootFolder,
+	}
+	if *ns != "" {
+		root := dropbox.PathRootNamespace(*ns)
+		client.PathRoot = &root
+	}
+
+	mirror := &subscriber.Mirror{
+		Folder:  *folder,
+		Dir:     *dir,
+		Client:  client,
+		Logger:  logger,
+		Cursors: cursors,
+	}
+
+	if *watch {
+		err = mirror.Watch(ctx)
+	} else {
+		err = mirror.Sync(ctx)
+	}
+	if err != nil && ctx.Err() == nil {
+		logger.Error(fmt.Sprintf("error mirroring: %v", err))
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -10,6 +11,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"sync/atomic"
 
 	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
@@ -18,6 +20,8 @@ import (
 
 var ErrStartup = errors.New("server is still starting up")
 
+const DefaultRevisionsLimit = 10
+
 func NewDropbox(clientSecret string, logger *slog.Logger) *Dropbox {
 	return &Dropbox{
 		Logger:       logger,
@@ -30,7 +34,7 @@ func NewDropbox(clientSecret string, logger *slog.Logger) *Dropbox {
 }
 
 type Dropbox struct {
-	Client       *dropbox.Client
+	Client       dropbox.Files
 	Logger       *slog.Logger
 	ClientSecret string
 
@@ -40,11 +44,14 @@ type Dropbox struct {
 	subscribers []Subscriber
 }
 
+// Subscriber handles the entries that changed in an account. Entries are
+// *dropbox.FileMetadata, *dropbox.FolderMetadata or, for removed paths,
+// *dropbox.DeletedMetadata.
 type Subscriber interface {
-	Handle(account string, files []dropbox.File) error
+	Handle(ctx context.Context, account string, entries []dropbox.Metadata) error
 }
 
-func (d *Dropbox) SetClient(client *dropbox.Client) {
+func (d *Dropbox) SetClient(client dropbox.Files) {
 	d.Client = client
 	d.ready.Store(true)
 }
@@ -62,7 +69,14 @@ func (d *Dropbox) DescribeFolder(w http.ResponseWriter, r *http.Request) {
 	folderName := r.URL.Query().Get("name")
 	cursor := r.URL.Query().Get("cursor")
 
-	folder, err := d.Client.ListFolder(folderName, cursor)
+	folder, err := d.Client.ListFolderContext(r.Context(), folderName, cursor)
+	if dropbox.IsNotFound(err) {
+		d.errHandler.Write(w, http.StatusNotFound, &Error{
+			Type:    "NotFound",
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
 			Type:    "BackendError",
@@ -99,7 +113,14 @@ func (d *Dropbox) DescribeFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, err := d.Client.DescribeFile(path)
+	file, err := d.Client.DescribeFileContext(r.Context(), path)
+	if dropbox.IsNotFound(err) {
+		d.errHandler.Write(w, http.StatusNotFound, &Error{
+			Type:    "NotFound",
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
 			Type:    "BackendError",
@@ -121,6 +142,211 @@ func (d *Dropbox) DescribeFile(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
+// AccountDescription is the body of the /dropbox/account route.
+type AccountDescription struct {
+	Account    *dropbox.FullAccount `json:"account"`
+	SpaceUsage *dropbox.SpaceUsage  `json:"space_usage"`
+}
+
+func (d *Dropbox) DescribeAccount(w http.ResponseWriter, r *http.Request) {
+	if !d.ready.Load() {
+		d.errHandler.Write(w, http.StatusServiceUnavailable, ErrStartup)
+		return
+	}
+
+	account, err := d.Client.GetCurrentAccountContext(r.Context())
+	if err != nil {
+		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
+			Type:    "BackendError",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	usage, err := d.Client.GetSpaceUsageContext(r.Context())
+	if err != nil {
+		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
+			Type:    "BackendError",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	body, err := json.Marshal(&AccountDescription{
+		Account:    account,
+		SpaceUsage: usage,
+	})
+	if err != nil {
+		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
+			Type:    "JSONError",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func (d *Dropbox) Search(w http.ResponseWriter, r *http.Request) {
+	if !d.ready.Load() {
+		d.errHandler.Write(w, http.StatusServiceUnavailable, ErrStartup)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	cursor := r.URL.Query().Get("cursor")
+	if len(query) == 0 && len(cursor) == 0 {
+		d.errHandler.Write(w, http.StatusBadRequest, &Error{
+			Type:    "MissingField",
+			Message: "missing `q` or `cursor` parameter in request URL",
+		})
+		return
+	}
+
+	var (
+		result *dropbox.SearchResult
+		err    error
+	)
+	if len(cursor) != 0 {
+		result, err = d.Client.SearchContinueContext(r.Context(), cursor)
+	} else {
+		result, err = d.Client.SearchContext(r.Context(), query, dropbox.SearchOptions{
+			Path:         r.URL.Query().Get("path"),
+			FilenameOnly: r.URL.Query().Get("filename_only") == "true",
+		})
+	}
+	if dropbox.IsNotFound(err) {
+		d.errHandler.Write(w, http.StatusNotFound, &Error{
+			Type:    "NotFound",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
+			Type:    "BackendError",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
+			Type:    "JSONError",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func (d *Dropbox) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	if !d.ready.Load() {
+		d.errHandler.Write(w, http.StatusServiceUnavailable, ErrStartup)
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if len(path) == 0 {
+		d.errHandler.Write(w, http.StatusBadRequest, &Error{
+			Type:    "MissingField",
+			Message: "missing `path` parameter in request URL",
+		})
+		return
+	}
+
+	limit := DefaultRevisionsLimit
+	if l := r.URL.Query().Get("limit"); len(l) != 0 {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > 100 {
+			d.errHandler.Write(w, http.StatusBadRequest, &Error{
+				Type:    "InvalidField",
+				Message: "`limit` must be between 1 and 100",
+			})
+			return
+		}
+	}
+
+	revisions, err := d.Client.ListRevisionsContext(r.Context(), path, limit)
+	if dropbox.IsNotFound(err) {
+		d.errHandler.Write(w, http.StatusNotFound, &Error{
+			Type:    "NotFound",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
+			Type:    "BackendError",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	body, err := json.Marshal(revisions)
+	if err != nil {
+		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
+			Type:    "JSONError",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func (d *Dropbox) Restore(w http.ResponseWriter, r *http.Request) {
+	if !d.ready.Load() {
+		d.errHandler.Write(w, http.StatusServiceUnavailable, ErrStartup)
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	rev := r.URL.Query().Get("rev")
+	if len(path) == 0 || len(rev) == 0 {
+		d.errHandler.Write(w, http.StatusBadRequest, &Error{
+			Type:    "MissingField",
+			Message: "missing `path` or `rev` parameter in request URL",
+		})
+		return
+	}
+
+	file, err := d.Client.RestoreContext(r.Context(), path, rev)
+	if dropbox.IsNotFound(err) {
+		d.errHandler.Write(w, http.StatusNotFound, &Error{
+			Type:    "NotFound",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
+			Type:    "BackendError",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	d.Logger.Info(fmt.Sprintf("restored %s to rev %s", file.PathDisplay, rev))
+
+	body, err := json.Marshal(file)
+	if err != nil {
+		d.errHandler.Write(w, http.StatusInternalServerError, &Error{
+			Type:    "JSONError",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
 func (d *Dropbox) VerifyWebhook(w http.ResponseWriter, r *http.Request) {
 	if !d.ready.Load() {
 		d.errHandler.Write(w, http.StatusServiceUnavailable, ErrStartup)
@@ -188,54 +414,61 @@ func (d *Dropbox) ReceiveUpdate(w http.ResponseWriter, r *http.Request) {
 	// return response before processing the update
 	w.WriteHeader(http.StatusAccepted)
 
-	if err := d.processUpdate(update.ListFolder.Accounts); err != nil {
+	if err := d.processUpdate(r.Context(), update.ListFolder.Accounts); err != nil {
 		d.Logger.Error(fmt.Sprintf("error processing update: %v", err))
 	}
 }
 
-func (d *Dropbox) processUpdate(accounts []dropbox.Account) error {
-	latest := ""
+func (d *Dropbox) processUpdate(ctx context.Context, accounts []dropbox.Account) error {
 	for _, a := range accounts {
-		account := string(a)
-
-		cursor, err := d.cursors.Get(account)
-		if err != nil && !errors.Is(err, store.ErrNotFound) {
+		if err := d.processAccount(ctx, string(a)); err != nil {
 			return err
 		}
-		if errors.Is(err, store.ErrNotFound) && latest == "" {
-			// no cursor in store yet; go get the latest
-			cursor, err := d.Client.GetLatestCursor("")
-			if err != nil {
-				return fmt.Errorf("error getting latest cursor: %w", err)
-			}
-
-			latest = cursor
-		}
-		if errors.Is(err, store.ErrNotFound) {
-			// store latest cursor so we can reference it on future calls
-			cursor = latest
-			d.cursors.Set(account, latest)
-			continue
-		}
+	}
+
+	return nil
+}
 
-		// get the delta from the previous cursor
-		folder, err := d.Client.ListFolder("", cursor)
+func (d *Dropbox) processAccount(ctx context.Context, account string) error {
+	cursor, err := d.cursors.Get(account)
+	if errors.Is(err, store.ErrNotFound) {
+		// no cursor in store yet; store the latest so we can reference it on
+		// future calls
+		latest, err := d.Client.GetLatestCursorContext(ctx, "")
 		if err != nil {
-			return fmt.Errorf("error listing folder for %s @ cursor %s: %w", account, cursor, err)
+			return fmt.Errorf("error getting latest cursor: %w", err)
 		}
 
-		// fan out the update to subscribers
-		for _, subscriber := range d.subscribers {
-			if err := subscriber.Handle(account, folder.Entries); err != nil {
-				return fmt.Errorf("error handling %s @ cursor %s: %w",
-					account, folder.Cursor, err,
-				)
-			}
-		}
+		d.cursors.Set(account, latest)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-		// store current cursor
-		d.cursors.Set(account, folder.Cursor)
+	// get the delta from the previous cursor
+	folder, err := d.Client.ListFolderAllContext(ctx, "", cursor)
+	if dropbox.IsCursorReset(err) {
+		// the cursor expired; start over from the latest one
+		d.Logger.Warn(fmt.Sprintf("cursor for %s was reset, changes since %s were missed", account, cursor))
+		d.cursors.Delete(account)
+		return d.processAccount(ctx, account)
 	}
+	if err != nil {
+		return fmt.Errorf("error listing folder for %s @ cursor %s: %w", account, cursor, err)
+	}
+
+	// fan out the update to subscribers
+	for _, subscriber := range d.subscribers {
+		if err := subscriber.Handle(ctx, account, folder.Entries); err != nil {
+			return fmt.Errorf("error handling %s @ cursor %s: %w",
+				account, folder.Cursor, err,
+			)
+		}
+	}
+
+	// store current cursor
+	d.cursors.Set(account, folder.Cursor)
 
 	return nil
 }
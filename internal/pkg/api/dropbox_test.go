@@ -0,0 +1,99 @@
+package api_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ice-cream-psychics-club/dropbox/internal/pkg/api"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox/dropboxtest"
+)
+
+type update struct {
+	account string
+	entries []dropbox.Metadata
+}
+
+type subscriberFunc func(ctx context.Context, account string, entries []dropbox.Metadata) error
+
+func (f subscriberFunc) Handle(ctx context.Context, account string, entries []dropbox.Metadata) error {
+	return f(ctx, account, entries)
+}
+
+func TestReceiveUpdate(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+	server.AppSecret = "secret"
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dbx := api.NewDropbox(server.AppSecret, logger)
+	dbx.SetClient(server.Client(logger))
+
+	updates := make(chan update, 1)
+	dbx.Subscribe(subscriberFunc(func(ctx context.Context, account string, entries []dropbox.Metadata) error {
+		updates <- update{account, entries}
+		return nil
+	}))
+
+	webhook := httptest.NewServer(http.HandlerFunc(dbx.ReceiveUpdate))
+	defer webhook.Close()
+	server.WebhookURL = webhook.URL
+
+	ctx := context.Background()
+
+	// the first notification only records where the account's changes start
+	if err := server.Notify(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Put("submissions.xlsx", []byte("submissions"))
+	if err := server.Notify(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case u := <-updates:
+		if u.account != dropboxtest.Account {
+			t.Errorf("account = %q, want %q", u.account, dropboxtest.Account)
+		}
+		// the root folder is created along with the file
+		var file *dropbox.FileMetadata
+		for _, e := range u.entries {
+			if f, ok := e.(*dropbox.FileMetadata); ok {
+				file = f
+			}
+		}
+		if file == nil || file.Name != "submissions.xlsx" {
+			t.Errorf("got entries %v, want submissions.xlsx among them", u.entries)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscriber wasn't called")
+	}
+}
+
+func TestReceiveUpdateRejectsBadSignatures(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+	server.AppSecret = "not the secret"
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dbx := api.NewDropbox("secret", logger)
+	dbx.SetClient(server.Client(logger))
+	dbx.Subscribe(subscriberFunc(func(ctx context.Context, account string, entries []dropbox.Metadata) error {
+		t.Error("subscriber called for a notification with a bad signature")
+		return nil
+	}))
+
+	webhook := httptest.NewServer(http.HandlerFunc(dbx.ReceiveUpdate))
+	defer webhook.Close()
+	server.WebhookURL = webhook.URL
+
+	if err := server.Notify(context.Background()); err == nil {
+		t.Error("notification with a bad signature was accepted")
+	}
+}
@@ -21,7 +21,7 @@ type ErrHandler struct {
 }
 
 func (erw *ErrHandler) Write(w http.ResponseWriter, statusCode int, err error) {
-	erw.Logger.Error(fmt.Sprintf("status code %d: %w", statusCode, err))
+	erw.Logger.Error(fmt.Sprintf("status code %d: %v", statusCode, err))
 
 	apiErr, ok := err.(*Error)
 	if !ok {
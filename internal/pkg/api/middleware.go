@@ -1,8 +1,10 @@
 package api
 
 import (
+	"crypto/subtle"
 	"log/slog"
 	"net/http"
+	"strings"
 )
 
 func LogRequests(logger *slog.Logger, next http.Handler) http.Handler {
@@ -11,3 +13,22 @@ func LogRequests(logger *slog.Logger, next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func RequireToken(logger *slog.Logger, token string, next http.Handler) http.Handler {
+	errHandler := ErrHandler{
+		Logger: logger,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(received), []byte(token)) == 0 {
+			errHandler.Write(w, http.StatusUnauthorized, &Error{
+				Type:    "Unauthorized",
+				Message: "missing or invalid bearer token",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/store"
+)
+
+var (
+	LongpollTimeout = 8 * time.Minute
+	// how long to wait before longpolling again after an error
+	WatchErrorDelay = 30 * time.Second
+)
+
+// Watch longpolls Dropbox for changes and fans them out to subscribers, the
+// same as updates received by ReceiveUpdate, until ctx is done. It's an
+// alternative to webhooks for when the server isn't publicly reachable.
+func (d *Dropbox) Watch(ctx context.Context, account string) error {
+	if !d.ready.Load() {
+		return ErrStartup
+	}
+
+	for {
+		delay, err := d.watchOnce(ctx, account)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			d.Logger.Error(fmt.Sprintf("error watching %s: %v", account, err))
+			delay = max(delay, WatchErrorDelay)
+		}
+
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// watchOnce waits for a change and processes it, returning how long Dropbox
+// asked us to back off for.
+func (d *Dropbox) watchOnce(ctx context.Context, account string) (time.Duration, error) {
+	cursor, err := d.cursors.Get(account)
+	if errors.Is(err, store.ErrNotFound) {
+		// initializes the cursor
+		return 0, d.processAccount(ctx, account)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := d.Client.ListFolderLongpollContext(ctx, cursor, LongpollTimeout)
+	if dropbox.IsCursorReset(err) {
+		// the cursor expired; start over from the latest one
+		d.Logger.Warn(fmt.Sprintf("cursor for %s was reset, changes since %s were missed", account, cursor))
+		d.cursors.Delete(account)
+		return 0, d.processAccount(ctx, account)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error longpolling %s @ cursor %s: %w", account, cursor, err)
+	}
+
+	delay := time.Duration(result.Backoff) * time.Second
+	if !result.Changes {
+		return delay, nil
+	}
+
+	return delay, d.processAccount(ctx, account)
+}
@@ -0,0 +1,78 @@
+package api_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ice-cream-psychics-club/dropbox/internal/pkg/api"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox/dropboxtest"
+)
+
+func TestWatchRecoversFromCursorReset(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := server.Client(logger)
+
+	// signal every longpoll as it's sent
+	longpolls := make(chan struct{}, 10)
+	client.Use(func(next http.RoundTripper) http.RoundTripper {
+		return dropbox.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/files/list_folder/longpoll") {
+				longpolls <- struct{}{}
+			}
+			return next.RoundTrip(req)
+		})
+	})
+
+	dbx := api.NewDropbox("secret", logger)
+	dbx.SetClient(client)
+
+	updates := make(chan update, 10)
+	dbx.Subscribe(subscriberFunc(func(ctx context.Context, account string, entries []dropbox.Metadata) error {
+		updates <- update{account, entries}
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dbx.Watch(ctx, dropboxtest.Account)
+
+	waitForLongpoll := func() {
+		t.Helper()
+		select {
+		case <-longpolls:
+		case <-time.After(5 * time.Second):
+			t.Fatal("watch didn't longpoll")
+		}
+	}
+
+	// expire the cursor being longpolled, and wake the longpoll up
+	waitForLongpoll()
+	server.ResetCursors()
+	server.Put("missed.txt", nil)
+
+	// changes after watch starts over from the latest cursor are seen again
+	waitForLongpoll()
+	server.Put("seen.txt", nil)
+
+	select {
+	case u := <-updates:
+		var names []string
+		for _, e := range u.entries {
+			names = append(names, e.Base().Name)
+		}
+		if len(names) != 1 || names[0] != "seen.txt" {
+			t.Errorf("got entries %v, want only seen.txt", names)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscriber wasn't called")
+	}
+}
@@ -1,6 +1,7 @@
 package subscriber
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 
@@ -11,14 +12,25 @@ type Logger struct {
 	*slog.Logger
 }
 
-func (l *Logger) Handle(account string, files []dropbox.File) error {
-	for _, f := range files {
-		fmt.Printf(`
+func (l *Logger) Handle(ctx context.Context, account string, entries []dropbox.Metadata) error {
+	for _, entry := range entries {
+		switch e := entry.(type) {
+		case *dropbox.FileMetadata:
+			fmt.Printf(`
 			name 			%s
 			content hash 	%s
 			modified at 	%s
 			modified by 	%s
-		`, f.Name, f.ContentHash, f.ClientModified, f.SharingInfo.ModifiedBy)
+		`, e.Name, e.ContentHash, e.ClientModified, e.SharingInfo.ModifiedBy)
+		case *dropbox.FolderMetadata:
+			fmt.Printf(`
+			folder 			%s
+		`, e.PathDisplay)
+		case *dropbox.DeletedMetadata:
+			fmt.Printf(`
+			deleted 		%s
+		`, e.PathDisplay)
+		}
 	}
 
 	return nil
@@ -0,0 +1,378 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/store"
+)
+
+var (
+	MirrorLongpollTimeout = 8 * time.Minute
+	// how long Watch waits before trying again after an error
+	MirrorErrorDelay = 30 * time.Second
+)
+
+// Mirror keeps a local directory in sync with a Dropbox folder. The copy is
+// read-only: local changes are overwritten or removed by the next sync.
+//
+// Entries are stored under their lower-cased paths, since Dropbox paths are
+// case-insensitive and only the last element of a display path is reliably
+// cased.
+type Mirror struct {
+	// Folder is the Dropbox folder to mirror, resolved with
+	// Client.ResolvePath; "" is the client's root folder
+	Folder string
+	// Dir is the local copy
+	Dir    string
+	Client dropbox.Files
+	Logger *slog.Logger
+	// Cursors persists where the last sync got to. Keep it outside Dir, or
+	// a full sync will remove it.
+	Cursors *store.FileStore
+	// ExportFormat is what files that can only be exported, like Google
+	// Sheets, are mirrored as, under their own names; defaults to each
+	// file's default export format
+	ExportFormat string
+
+	mu sync.Mutex
+}
+
+// Handle syncs the mirror. The entries are only taken as a sign that something
+// changed: the mirror lists changes from its own cursor, so it catches up on
+// anything it missed while it wasn't running.
+func (m *Mirror) Handle(ctx context.Context, account string, entries []dropbox.Metadata) error {
+	return m.Sync(ctx)
+}
+
+// Sync applies the changes since the last sync or, the first time or after
+// Dropbox resets the cursor, copies the whole folder and removes anything
+// local that isn't in it.
+func (m *Mirror) Sync(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating %s: %w", m.Dir, err)
+	}
+
+	cursor, err := m.Cursors.Get(m.cursorKey())
+	full := errors.Is(err, store.ErrNotFound)
+	if err != nil && !full {
+		return err
+	}
+
+	folder, err := m.Client.ListFolderAllContext(ctx, m.Folder, cursor)
+	if dropbox.IsCursorReset(err) {
+		m.Logger.Warn("subscriber.Mirror: cursor was reset, syncing " + m.Dir + " from scratch")
+		full = true
+		folder, err = m.Client.ListFolderAllContext(ctx, m.Folder, "")
+	}
+	if err != nil {
+		return fmt.Errorf("error listing %s: %w", m.Folder, err)
+	}
+
+	if err := m.apply(ctx, folder.Entries); err != nil {
+		return err
+	}
+	if full {
+		if err := m.prune(folder.Entries); err != nil {
+			return err
+		}
+	}
+
+	if err := m.Cursors.Set(m.cursorKey(), folder.Cursor); err != nil {
+		return fmt.Errorf("error saving cursor: %w", err)
+	}
+
+	return nil
+}
+
+// Watch syncs the mirror whenever the folder changes, until ctx is done.
+func (m *Mirror) Watch(ctx context.Context) error {
+	for {
+		delay, err := m.watchOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			m.Logger.Error(fmt.Sprintf("subscriber.Mirror: error syncing %s: %v", m.Dir, err))
+			delay = max(delay, MirrorErrorDelay)
+		}
+
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// watchOnce syncs and then waits for the next change, returning how long
+// Dropbox asked us to back off for.
+func (m *Mirror) watchOnce(ctx context.Context) (time.Duration, error) {
+	if err := m.Sync(ctx); err != nil {
+		return 0, err
+	}
+
+	cursor, err := m.Cursors.Get(m.cursorKey())
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := m.Client.ListFolderLongpollContext(ctx, cursor, MirrorLongpollTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("error longpolling: %w", err)
+	}
+
+	return time.Duration(result.Backoff) * time.Second, nil
+}
+
+func (m *Mirror) cursorKey() string {
+	return "mirror:" + strings.ToLower(m.Client.ResolvePath(m.Folder))
+}
+
+// apply applies the entries in the order they're listed, since a path can be
+// deleted and then created again in the same listing. Deleted entries are
+// moved aside until the end, so that renamed files can be moved from where
+// they were rather than downloaded again.
+func (m *Mirror) apply(ctx context.Context, entries []dropbox.Metadata) error {
+	var trash string
+	defer func() {
+		if trash != "" {
+			os.RemoveAll(trash)
+		}
+	}()
+
+	// deleted files that have been moved aside, by content hash
+	deleted := make(map[string]string)
+
+	for i, entry := range entries {
+		switch e := entry.(type) {
+		case *dropbox.FolderMetadata:
+			local, ok := m.local(e.PathLower)
+			if !ok {
+				continue
+			}
+			if info, err := os.Lstat(local); err == nil && !info.IsDir() {
+				// it was a file
+				os.Remove(local)
+			}
+			if err := os.MkdirAll(local, 0o755); err != nil {
+				return fmt.Errorf("error creating %s: %w", local, err)
+			}
+		case *dropbox.FileMetadata:
+			if err := m.syncFile(ctx, e, deleted); err != nil {
+				return err
+			}
+		case *dropbox.DeletedMetadata:
+			local, ok := m.local(e.PathLower)
+			if !ok {
+				continue
+			}
+			if _, err := os.Lstat(local); err != nil {
+				// never mirrored, or already removed with its folder
+				continue
+			}
+
+			if trash == "" {
+				var err error
+				trash, err = os.MkdirTemp(filepath.Dir(m.Dir), "."+filepath.Base(m.Dir)+".deleted-*")
+				if err != nil {
+					return fmt.Errorf("error creating temporary directory: %w", err)
+				}
+			}
+			aside := filepath.Join(trash, strconv.Itoa(i))
+			if err := os.Rename(local, aside); err != nil {
+				return fmt.Errorf("error removing %s: %w", local, err)
+			}
+			indexHashes(aside, deleted)
+			m.Logger.Info("subscriber.Mirror: removed " + local)
+		}
+	}
+
+	return nil
+}
+
+func (m *Mirror) syncFile(ctx context.Context, f *dropbox.FileMetadata, deleted map[string]string) error {
+	local, ok := m.local(f.PathLower)
+	if !ok {
+		return nil
+	}
+	if info, err := os.Lstat(local); err == nil && info.IsDir() {
+		// it was a folder
+		if err := os.RemoveAll(local); err != nil {
+			return fmt.Errorf("error removing %s: %w", local, err)
+		}
+	} else if hash, err := hashFile(local); err == nil && hash == f.ContentHash {
+		return nil
+	}
+
+	// move renamed files instead of downloading them again
+	if from, ok := deleted[f.ContentHash]; ok && f.ContentHash != "" {
+		delete(deleted, f.ContentHash)
+		if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+			return fmt.Errorf("error creating %s: %w", filepath.Dir(local), err)
+		}
+		if err := os.Rename(from, local); err == nil {
+			m.Logger.Info("subscriber.Mirror: moved " + local + " from a deleted copy")
+			return nil
+		}
+	}
+
+	return m.download(ctx, f, local)
+}
+
+// download writes the file, or its export if it can't be downloaded, to a
+// temporary file next to local, which replaces local once the download's
+// content hash has been verified.
+func (m *Mirror) download(ctx context.Context, f *dropbox.FileMetadata, local string) error {
+	dir := filepath.Dir(local)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating %s: %w", dir, err)
+	}
+
+	file, body, err := m.read(ctx, f)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(local)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// the body fails with dropbox.ErrContentHashMismatch if it's corrupt
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error downloading %s: %w", f.PathDisplay, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %w", tmp.Name(), err)
+	}
+
+	if err := os.Rename(tmp.Name(), local); err != nil {
+		return fmt.Errorf("error replacing %s: %w", local, err)
+	}
+	modified := file.ClientModified
+	if err := os.Chtimes(local, modified, modified); err != nil {
+		m.Logger.Warn(fmt.Sprintf("subscriber.Mirror: error setting times of %s: %v", local, err))
+	}
+
+	m.Logger.Info(fmt.Sprintf("subscriber.Mirror: downloaded %s rev %s", local, file.Rev))
+	return nil
+}
+
+// read downloads the file or, if it can't be downloaded, exports it.
+func (m *Mirror) read(ctx context.Context, f *dropbox.FileMetadata) (*dropbox.FileMetadata, io.ReadCloser, error) {
+	if f.IsDownloadable {
+		result, err := m.Client.DownloadContext(ctx, f.PathLower)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error downloading %s: %w", f.PathDisplay, err)
+		}
+
+		return &result.File, result.Body, nil
+	}
+
+	result, err := m.Client.ExportContext(ctx, f.PathLower, m.ExportFormat)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error exporting %s: %w", f.PathDisplay, err)
+	}
+
+	return &result.File, result.Body, nil
+}
+
+// prune removes everything local that isn't one of entries.
+func (m *Mirror) prune(entries []dropbox.Metadata) error {
+	keep := make(map[string]bool)
+	for _, entry := range entries {
+		if _, ok := entry.(*dropbox.DeletedMetadata); ok {
+			continue
+		}
+		if local, ok := m.local(entry.Base().PathLower); ok {
+			keep[local] = true
+		}
+	}
+
+	return filepath.WalkDir(m.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == m.Dir || keep[path] {
+			return nil
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("error removing %s: %w", path, err)
+		}
+		m.Logger.Info("subscriber.Mirror: removed " + path)
+
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// local maps a Dropbox path to where it's mirrored, if it's in the mirrored
+// folder.
+func (m *Mirror) local(pathLower string) (string, bool) {
+	folder := strings.ToLower(m.Client.ResolvePath(m.Folder))
+	if pathLower != folder && !strings.HasPrefix(pathLower, folder+"/") {
+		return "", false
+	}
+
+	rel := strings.TrimPrefix(strings.TrimPrefix(pathLower, folder), "/")
+	if rel == "" {
+		return m.Dir, true
+	}
+
+	rel = filepath.FromSlash(rel)
+	if !filepath.IsLocal(rel) {
+		return "", false
+	}
+
+	return filepath.Join(m.Dir, rel), true
+}
+
+// indexHashes adds the content hash of every file at or under path to hashes.
+func indexHashes(path string, hashes map[string]string) {
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if hash, err := hashFile(p); err == nil {
+			hashes[hash] = p
+		}
+		return nil
+	})
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return dropbox.ContentHash(f)
+}
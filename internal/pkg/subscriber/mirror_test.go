@@ -0,0 +1,121 @@
+package subscriber_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ice-cream-psychics-club/dropbox/internal/pkg/subscriber"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox/dropboxtest"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/store"
+)
+
+func newMirror(t *testing.T, server *dropboxtest.Server) *subscriber.Mirror {
+	t.Helper()
+
+	cursors, err := store.NewFileStore(filepath.Join(t.TempDir(), "cursors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &subscriber.Mirror{
+		Folder:  "mirrored",
+		Dir:     filepath.Join(t.TempDir(), "mirror"),
+		Client:  server.Client(nil),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Cursors: cursors,
+	}
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	if string(got) != want {
+		t.Errorf("%s = %q, want %q", path, got, want)
+	}
+}
+
+func TestMirrorDeleteThenRecreate(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	server.Put("mirrored/a.txt", []byte("old a"))
+	server.Put("mirrored/x/f.txt", []byte("f"))
+
+	ctx := context.Background()
+	mirror := newMirror(t, server)
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(mirror.Dir, "a.txt"), "old a")
+	assertFile(t, filepath.Join(mirror.Dir, "x", "f.txt"), "f")
+
+	// both are listed as deleted and then created again in the same page
+	server.Delete("mirrored/a.txt")
+	server.Put("mirrored/a.txt", []byte("new a"))
+	server.Delete("mirrored/x")
+	server.Put("mirrored/x", []byte("x is a file now"))
+
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(mirror.Dir, "a.txt"), "new a")
+	assertFile(t, filepath.Join(mirror.Dir, "x"), "x is a file now")
+
+	// nothing is left behind next to the mirror
+	siblings, err := os.ReadDir(filepath.Dir(mirror.Dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(siblings) != 1 {
+		t.Errorf("got %d entries next to the mirror, want only the mirror", len(siblings))
+	}
+}
+
+func TestMirrorRename(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	server.Put("mirrored/before.txt", []byte("renamed"))
+
+	ctx := context.Background()
+	mirror := newMirror(t, server)
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Delete("mirrored/before.txt")
+	server.Put("mirrored/after.txt", []byte("renamed"))
+
+	if err := mirror.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(mirror.Dir, "after.txt"), "renamed")
+	if _, err := os.Lstat(filepath.Join(mirror.Dir, "before.txt")); !os.IsNotExist(err) {
+		t.Errorf("before.txt is still mirrored: %v", err)
+	}
+}
+
+func TestMirrorExportsSheets(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	server.PutNative("mirrored/responses.gsheet", map[string][]byte{
+		"xlsx": []byte("xlsx responses"),
+		"csv":  []byte("csv responses"),
+	}, "xlsx")
+
+	mirror := newMirror(t, server)
+	mirror.ExportFormat = "csv"
+	if err := mirror.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, filepath.Join(mirror.Dir, "responses.gsheet"), "csv responses")
+}
@@ -1,58 +1,279 @@
 package subscriber
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"time"
 
 	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
 )
 
+const DefaultMaxAttempts = 3
+
+// how often a lock held by someone else is checked while waiting for it
+var LockPollInterval = 5 * time.Second
+
 type Propagator struct {
 	Source  string
 	Targets []Target
-	Client  *dropbox.Client
+	Client  dropbox.Files
 	Logger  *slog.Logger
+	// MaxAttempts bounds how many times a target is propagated when it
+	// changes between being read and written; defaults to DefaultMaxAttempts
+	MaxAttempts int
+	// Lock, if set, locks each target while it's propagated
+	Lock *LockPolicy
+	// ExportFormat is what sources that can only be exported, like Google
+	// Sheets, are exported as, e.g. "xlsx" or "csv"; defaults to the
+	// source's own default export format
+	ExportFormat string
+}
+
+// LockPolicy decides what to do when someone else holds a target's lock.
+type LockPolicy struct {
+	// Wait is how long to wait for the lock to be released; zero doesn't wait
+	Wait time.Duration
+	// Skip the target rather than fail when the lock isn't released in time
+	Skip bool
 }
 
 type Target struct {
 	Name      string
-	Transform func(r io.Reader) (io.Reader, error)
+	Transform func(ctx context.Context, r io.Reader) (io.Reader, error)
+	// SharedLink, if set, shares the target with these settings once it's
+	// uploaded, reusing its existing link if it has one
+	SharedLink *dropbox.SharedLinkSettings
+	// OnShared receives the target's shared link; by default it's logged
+	OnShared func(link *dropbox.SharedLink)
 }
 
-func (p *Propagator) Handle(account string, files []dropbox.File) error {
-	var propagate *dropbox.File
-	for _, f := range files {
-		// TODO: check f.IsDownloadable
-		if f.Name == p.Source {
-			propagate = &f
-			break
-		} else {
-			p.Logger.Debug("skipping " + f.Name)
+func (p *Propagator) Handle(ctx context.Context, account string, entries []dropbox.Metadata) error {
+	var propagate *dropbox.FileMetadata
+	for _, entry := range entries {
+		switch e := entry.(type) {
+		case *dropbox.FileMetadata:
+			if p.Client.MatchesPath(e, p.Source) {
+				propagate = e
+			} else {
+				p.Logger.Debug("skipping " + e.Name)
+			}
+		case *dropbox.DeletedMetadata:
+			if p.Client.MatchesPath(e, p.Source) {
+				// nothing to propagate, leave the targets as they were
+				p.Logger.Info("subscriber.Propagator: " + e.Name + " was deleted, skipping")
+				propagate = nil
+			}
+		default:
+			p.Logger.Debug("skipping " + entry.Base().Name)
 		}
 	}
 	if propagate == nil {
 		return nil
 	}
 
-	p.Logger.Info("subscriber.Propagator: " + propagate.Name)
+	p.Logger.Info("subscriber.Propagator: " + propagate.PathDisplay)
+
+	// TODO: best-effort
+	for _, t := range p.Targets {
+		if err := p.propagate(ctx, propagate, t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// propagate runs the download→transform→upload cycle for a target, starting
+// over whenever the target's rev moves while the cycle is in flight.
+func (p *Propagator) propagate(ctx context.Context, source *dropbox.FileMetadata, t Target) error {
+	if p.Lock != nil {
+		unlock, err := p.lock(ctx, t.Name)
+		if errors.Is(err, dropbox.ErrLockConflict) && p.Lock.Skip {
+			p.Logger.Warn("subscriber.Propagator: skipping " + t.Name + ": " + err.Error())
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	maxAttempts := p.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := p.propagateOnce(ctx, source, t)
 
-	in, err := p.Client.Download(propagate.Name)
+		var conflict *dropbox.ConflictError
+		if !errors.As(err, &conflict) || attempt >= maxAttempts {
+			return err
+		}
+
+		p.Logger.Warn(fmt.Sprintf("subscriber.Propagator: %s changed while propagating, retrying (attempt %d of %d): %v",
+			t.Name, attempt+1, maxAttempts, err,
+		))
+	}
+}
+
+func (p *Propagator) propagateOnce(ctx context.Context, source *dropbox.FileMetadata, t Target) error {
+	// only write over the version of the target we started from
+	mode := dropbox.WriteModeAdd
+	metadata, err := p.Client.DescribeFileContext(ctx, t.Name)
+	target, isFile := metadata.(*dropbox.FileMetadata)
+
+	switch {
+	case err == nil && !isFile:
+		return fmt.Errorf("target %s is a %s, not a file", t.Name, metadata.Base().Tag)
+	case err == nil:
+		mode = dropbox.WriteModeUpdate(target.Rev)
+	case dropbox.IsNotFound(err):
+		// the target doesn't exist yet
+	default:
+		return fmt.Errorf("error describing target: %w", err)
+	}
+
+	in, body, err := p.read(ctx, source)
 	if err != nil {
-		return fmt.Errorf("error requesting download: %w", err)
+		return err
 	}
+	defer body.Close()
 
-	// TODO: best-effort
-	for _, t := range p.Targets {
-		out, err := t.Transform(in)
+	if in.Rev != source.Rev {
+		p.Logger.Info(fmt.Sprintf("subscriber.Propagator: %s moved from rev %s to %s since the update",
+			source.PathDisplay, source.Rev, in.Rev,
+		))
+	}
+
+	out, err := t.Transform(ctx, body)
+	if err != nil {
+		return fmt.Errorf("error transforming source to target: %w", err)
+	}
+
+	// skip uploads that wouldn't change the target
+	buff := &bytes.Buffer{}
+	hash := dropbox.NewContentHash()
+	if _, err := io.Copy(io.MultiWriter(buff, hash), out); err != nil {
+		return fmt.Errorf("error reading transformed target: %w", err)
+	}
+	if target != nil && target.ContentHash == hex.EncodeToString(hash.Sum(nil)) {
+		p.Logger.Info("subscriber.Propagator: " + t.Name + " unchanged, skipping upload")
+		return nil
+	}
+
+	uploaded, err := p.Client.UploadWithOptionsContext(ctx, t.Name, buff, dropbox.UploadOptions{Mode: mode})
+	if err != nil {
+		return fmt.Errorf("error uploading target: %w", err)
+	}
+	p.Logger.Info(fmt.Sprintf("subscriber.Propagator: propagated %s rev %s to %s rev %s",
+		in.PathDisplay, in.Rev, uploaded.PathDisplay, uploaded.Rev,
+	))
+
+	if t.SharedLink != nil {
+		return p.share(ctx, t)
+	}
+
+	return nil
+}
+
+// read downloads the source or, if it can't be downloaded, exports it.
+func (p *Propagator) read(ctx context.Context, source *dropbox.FileMetadata) (*dropbox.FileMetadata, io.ReadCloser, error) {
+	if source.IsDownloadable {
+		result, err := p.Client.DownloadContext(ctx, source.PathLower)
 		if err != nil {
-			return fmt.Errorf("error transforming source to target: %w", err)
+			return nil, nil, fmt.Errorf("error requesting download: %w", err)
 		}
 
-		if err := p.Client.Upload(t.Name, out); err != nil {
-			return fmt.Errorf("error uploading target: %w", err)
+		return &result.File, result.Body, nil
+	}
+
+	result, err := p.Client.ExportContext(ctx, source.PathLower, p.ExportFormat)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error requesting export: %w", err)
+	}
+	p.Logger.Info("subscriber.Propagator: exported " + source.PathDisplay + " as " + result.Export.Name)
+
+	return &result.File, result.Body, nil
+}
+
+func (p *Propagator) share(ctx context.Context, t Target) error {
+	link, err := p.Client.CreateSharedLinkContext(ctx, t.Name, t.SharedLink)
+	if errors.Is(err, dropbox.ErrSharedLinkExists) {
+		links, listErr := p.Client.ListSharedLinksContext(ctx, t.Name, true)
+		if listErr != nil {
+			return fmt.Errorf("error listing shared links: %w", listErr)
 		}
+		if len(links) == 0 {
+			return fmt.Errorf("error finding existing shared link: %w", err)
+		}
+
+		link, err = &links[0], nil
+	}
+	if err != nil {
+		return fmt.Errorf("error sharing target: %w", err)
 	}
 
+	if t.OnShared != nil {
+		t.OnShared(link)
+		return nil
+	}
+
+	p.Logger.Info("subscriber.Propagator: shared " + t.Name + " at " + link.URL)
 	return nil
 }
+
+// lock locks the target, waiting for someone else's lock to be released
+// according to the lock policy. Targets that don't exist yet can't be locked,
+// so they're left unlocked.
+func (p *Propagator) lock(ctx context.Context, name string) (func(), error) {
+	deadline := time.Now().Add(p.Lock.Wait)
+
+	for {
+		results, err := p.Client.LockFileBatchContext(ctx, []string{name})
+		if err != nil {
+			return nil, fmt.Errorf("error locking %s: %w", name, err)
+		}
+		if len(results) != 1 {
+			return nil, fmt.Errorf("error locking %s: got %d results", name, len(results))
+		}
+
+		failure := results[0].Failure
+		switch {
+		case failure == nil:
+			return func() { p.unlock(name) }, nil
+		case errors.Is(failure, dropbox.ErrNotFound):
+			return func() {}, nil
+		case !errors.Is(failure, dropbox.ErrLockConflict):
+			return nil, fmt.Errorf("error locking %s: %w", name, failure)
+		case time.Now().Add(LockPollInterval).After(deadline):
+			return nil, fmt.Errorf("%s: %w", name, dropbox.ErrLockConflict)
+		}
+
+		p.Logger.Info("subscriber.Propagator: waiting for lock on " + name)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(LockPollInterval):
+		}
+	}
+}
+
+func (p *Propagator) unlock(name string) {
+	// unlock even if the propagation was cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	results, err := p.Client.UnlockFileBatchContext(ctx, []string{name})
+	if err == nil && len(results) == 1 && results[0].Failure != nil {
+		err = results[0].Failure
+	}
+	if err != nil {
+		p.Logger.Error(fmt.Sprintf("subscriber.Propagator: error unlocking %s: %v", name, err))
+	}
+}
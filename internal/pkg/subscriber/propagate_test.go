@@ -0,0 +1,103 @@
+package subscriber_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/ice-cream-psychics-club/dropbox/internal/pkg/subscriber"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox/dropboxtest"
+)
+
+func upper(ctx context.Context, r io.Reader) (io.Reader, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(bytes.ToUpper(b)), nil
+}
+
+func assertContent(t *testing.T, server *dropboxtest.Server, path, want string) {
+	t.Helper()
+
+	got, ok := server.Content(path)
+	if !ok {
+		t.Fatalf("%s doesn't exist", path)
+	}
+	if string(got) != want {
+		t.Errorf("%s = %q, want %q", path, got, want)
+	}
+}
+
+func TestPropagator(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	source := server.Put("source.txt", []byte("hello"))
+
+	ctx := context.Background()
+	p := &subscriber.Propagator{
+		Source:  "source.txt",
+		Targets: []subscriber.Target{{Name: "target.txt", Transform: upper}},
+		Client:  server.Client(nil),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	if err := p.Handle(ctx, dropboxtest.Account, []dropbox.Metadata{source}); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, server, "target.txt", "HELLO")
+	first, _ := server.Metadata("target.txt")
+
+	// propagating the same source again leaves the target alone
+	if err := p.Handle(ctx, dropboxtest.Account, []dropbox.Metadata{source}); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := server.Metadata("target.txt")
+	if first.(*dropbox.FileMetadata).Rev != second.(*dropbox.FileMetadata).Rev {
+		t.Error("unchanged target was uploaded again")
+	}
+
+	source = server.Put("source.txt", []byte("goodbye"))
+	if err := p.Handle(ctx, dropboxtest.Account, []dropbox.Metadata{source}); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, server, "target.txt", "GOODBYE")
+}
+
+func TestPropagatorExportsSheets(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	source := server.PutNative("responses.gsheet", map[string][]byte{
+		"xlsx": []byte("xlsx responses"),
+		"csv":  []byte("csv responses"),
+	}, "xlsx")
+
+	ctx := context.Background()
+	p := &subscriber.Propagator{
+		Source: "responses.gsheet",
+		Targets: []subscriber.Target{{Name: "responses.txt", Transform: func(ctx context.Context, r io.Reader) (io.Reader, error) {
+			return r, nil
+		}}},
+		Client:       server.Client(nil),
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ExportFormat: "csv",
+	}
+
+	if err := p.Handle(ctx, dropboxtest.Account, []dropbox.Metadata{source}); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, server, "responses.txt", "csv responses")
+
+	// the sheet's own export format by default
+	p.ExportFormat = ""
+	if err := p.Handle(ctx, dropboxtest.Account, []dropbox.Metadata{source}); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, server, "responses.txt", "xlsx responses")
+}
@@ -0,0 +1,150 @@
+package dropbox
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachingFiles caches file metadata and the current account for TTL. Cached
+// metadata is dropped when it's written through CachingFiles or shows up in a
+// folder listing, so it's only stale for changes made elsewhere that haven't
+// been listed yet. Results are cached separately for each path root and team
+// member selected with WithPathRoot and WithSelector.
+type CachingFiles struct {
+	Files
+	TTL time.Duration
+
+	mu       sync.Mutex
+	metadata map[metadataKey]cached[Metadata]
+	accounts map[teamScope]cached[*FullAccount]
+}
+
+type metadataKey struct {
+	scope teamScope
+	path  string
+}
+
+type cached[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func (f *CachingFiles) DescribeFileContext(ctx context.Context, filePath string) (Metadata, error) {
+	key := metadataKey{scopeOf(ctx), strings.ToLower(filePath)}
+
+	f.mu.Lock()
+	entry, ok := f.metadata[key]
+	f.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	m, err := f.Files.DescribeFileContext(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.metadata == nil {
+		f.metadata = make(map[metadataKey]cached[Metadata])
+	}
+	f.metadata[key] = cached[Metadata]{value: m, expires: time.Now().Add(f.TTL)}
+
+	return m, nil
+}
+
+func (f *CachingFiles) GetCurrentAccountContext(ctx context.Context) (*FullAccount, error) {
+	scope := scopeOf(ctx)
+
+	f.mu.Lock()
+	entry, ok := f.accounts[scope]
+	f.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	account, err := f.Files.GetCurrentAccountContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.accounts == nil {
+		f.accounts = make(map[teamScope]cached[*FullAccount])
+	}
+	f.accounts[scope] = cached[*FullAccount]{value: account, expires: time.Now().Add(f.TTL)}
+
+	return account, nil
+}
+
+func (f *CachingFiles) ListFolderContext(ctx context.Context, folderPath, cursor string) (*Folder, error) {
+	folder, err := f.Files.ListFolderContext(ctx, folderPath, cursor)
+	if err == nil {
+		f.invalidateEntries(folder.Entries)
+	}
+
+	return folder, err
+}
+
+func (f *CachingFiles) ListFolderAllContext(ctx context.Context, folderPath, cursor string) (*Folder, error) {
+	folder, err := f.Files.ListFolderAllContext(ctx, folderPath, cursor)
+	if err == nil {
+		f.invalidateEntries(folder.Entries)
+	}
+
+	return folder, err
+}
+
+func (f *CachingFiles) UploadWithOptionsContext(ctx context.Context, filePath string, r io.Reader, opts UploadOptions) (*FileMetadata, error) {
+	defer f.invalidate(filePath)
+	return f.Files.UploadWithOptionsContext(ctx, filePath, r, opts)
+}
+
+func (f *CachingFiles) RestoreContext(ctx context.Context, path, rev string) (*FileMetadata, error) {
+	defer f.invalidate(path)
+	return f.Files.RestoreContext(ctx, path, rev)
+}
+
+func (f *CachingFiles) LockFileBatchContext(ctx context.Context, paths []string) ([]FileLockResult, error) {
+	defer f.invalidate(paths...)
+	return f.Files.LockFileBatchContext(ctx, paths)
+}
+
+func (f *CachingFiles) UnlockFileBatchContext(ctx context.Context, paths []string) ([]FileLockResult, error) {
+	defer f.invalidate(paths...)
+	return f.Files.UnlockFileBatchContext(ctx, paths)
+}
+
+// invalidate drops the metadata cached for paths, however they were spelled
+// when it was cached and whichever namespace it was cached for.
+func (f *CachingFiles) invalidate(paths ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for key, entry := range f.metadata {
+		for _, p := range paths {
+			if key.path == strings.ToLower(p) || f.Files.MatchesPath(entry.value, p) {
+				delete(f.metadata, key)
+				break
+			}
+		}
+	}
+}
+
+func (f *CachingFiles) invalidateEntries(entries Entries) {
+	if len(entries) == 0 {
+		return
+	}
+
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		paths[i] = e.Base().PathLower
+	}
+
+	f.invalidate(paths...)
+}
@@ -0,0 +1,41 @@
+package dropbox_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox/dropboxtest"
+)
+
+func TestCachingFilesScopes(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	server.Put("a.txt", nil)
+
+	client := server.Client(nil)
+	describes := countRequests(client, "/files/get_metadata")
+	files := &dropbox.CachingFiles{Files: client, TTL: time.Minute}
+
+	ctx := context.Background()
+	scoped := []context.Context{
+		ctx,
+		dropbox.WithPathRoot(ctx, dropbox.PathRootNamespace("2")),
+		dropbox.WithSelector(ctx, dropbox.SelectUser("dbmid:member")),
+		dropbox.WithSelector(ctx, dropbox.SelectAdmin("dbmid:member")),
+	}
+
+	// each namespace and member is looked up once, then cached
+	for range 2 {
+		for _, ctx := range scoped {
+			if _, err := files.DescribeFileContext(ctx, "a.txt"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if got := describes.Load(); got != int32(len(scoped)) {
+		t.Errorf("got %d get_metadata requests, want %d", got, len(scoped))
+	}
+}
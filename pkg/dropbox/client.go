@@ -2,6 +2,7 @@ package dropbox
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,14 +11,32 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 const (
-	BaseURL        = "https://api.dropboxapi.com/2"
-	BaseNotifyURL  = "https://notify.dropboxapi.com/2"
-	BaseContentURL = "https://content.dropboxapi.com/2"
+	DefaultBaseURL    = "https://api.dropboxapi.com/2"
+	DefaultNotifyURL  = "https://notify.dropboxapi.com/2"
+	DefaultContentURL = "https://content.dropboxapi.com/2"
 
-	RootFolder = "/apps/content-selection/"
+	DefaultRootFolder = "/apps/content-selection"
+
+	minLongpollTimeout = 30 * time.Second
+	maxLongpollTimeout = 480 * time.Second
+)
+
+const (
+	// Deprecated: use DefaultBaseURL, or Client.BaseURL to point a client
+	// elsewhere.
+	BaseURL = DefaultBaseURL
+	// Deprecated: use DefaultNotifyURL, or Client.NotifyURL.
+	BaseNotifyURL = DefaultNotifyURL
+	// Deprecated: use DefaultContentURL, or Client.ContentURL.
+	BaseContentURL = DefaultContentURL
+
+	// Deprecated: use DefaultRootFolder, which has no trailing slash, or
+	// Client.RootFolder.
+	RootFolder = DefaultRootFolder + "/"
 )
 
 type Header struct {
@@ -35,48 +54,112 @@ func (e *ClientErr) Error() string {
 	return fmt.Sprintf("status code %d from %s: %v", e.StatusCode, e.Path, e.Cause)
 }
 
+func (e *ClientErr) Unwrap() error {
+	return e.Cause
+}
+
+// ConflictError is returned by uploads when the write mode's expectations
+// don't hold, e.g. the file's rev moved on since it was read.
+type ConflictError struct {
+	Path    string
+	Summary string
+	Cause   error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict writing %s: %s", e.Path, e.Summary)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Cause
+}
+
 type Client struct {
 	HTTPClient *http.Client
 	Logger     *slog.Logger
+	// BaseURL, NotifyURL and ContentURL are the API's RPC, longpoll and
+	// content endpoints; they default to DefaultBaseURL, DefaultNotifyURL
+	// and DefaultContentURL when empty
+	BaseURL    string
+	NotifyURL  string
+	ContentURL string
+	// RootFolder is what relative paths are resolved against, within the
+	// path root; defaults to DefaultRootFolder when empty
+	RootFolder string
+	// PathRoot is the namespace paths are relative to; defaults to the
+	// user's home when nil. See also WithPathRoot.
+	PathRoot *PathRoot
+	// Selector is the team member a team token acts as; see also
+	// WithSelector
+	Selector *Selector
+	// Retry defaults to DefaultRetryPolicy when nil
+	Retry *RetryPolicy
+	// UploadChunkSize and UploadSessionThreshold default to
+	// DefaultUploadChunkSize and DefaultUploadSessionThreshold when zero
+	UploadChunkSize        int64
+	UploadSessionThreshold int64
+	// PropertyTemplateIDs are the templates whose property groups are
+	// included in file and folder metadata
+	PropertyTemplateIDs []string
+	// ZipConcurrency is how many files BuildZip downloads at once; defaults
+	// to DefaultZipConcurrency when zero
+	ZipConcurrency int
+	// Middleware wraps the transport of every request; see Use
+	Middleware []Middleware
 }
 
-func (c *Client) DescribeFile(filePath string) (*File, error) {
+func (c *Client) DescribeFile(filePath string) (Metadata, error) {
+	return c.DescribeFileContext(context.Background(), filePath)
+}
+
+func (c *Client) DescribeFileContext(ctx context.Context, filePath string) (Metadata, error) {
+	filePath = c.ResolvePath(filePath)
+
 	urlPath := "/files/get_metadata"
-	url := BaseURL + urlPath
+	url := c.baseURL() + urlPath
 	params := map[string]any{
 		"path": filePath,
 	}
+	c.includePropertyGroups(params)
 	c.Logger.Debug("client.DescribeFile: " + url)
 
-	req, err := newJSONRequest("POST", url, params, Header{
+	req, err := c.newJSONRequest(ctx, "POST", url, params, Header{
 		"Content-Type", "application/json",
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	var file File
-	if err := c.doRequest(req, urlPath, &file); err != nil {
+	var raw json.RawMessage
+	if err := c.doRequest(req, urlPath, &raw); err != nil {
 		return nil, err
 	}
 
-	return &file, nil
+	metadata, err := UnmarshalMetadata(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s response body: %w", urlPath, err)
+	}
+
+	return metadata, nil
 }
 
 func (c *Client) GetLatestCursor(filePath string) (string, error) {
-	if _, found := strings.CutPrefix(filePath, "/"); !found {
-		filePath = RootFolder + filePath
-	}
+	return c.GetLatestCursorContext(context.Background(), filePath)
+}
+
+func (c *Client) GetLatestCursorContext(ctx context.Context, filePath string) (string, error) {
+	filePath = c.ResolvePath(filePath)
 
 	params := map[string]any{
 		"path":      filePath,
 		"recursive": true,
 	}
+	c.includePropertyGroups(params)
 	urlPath := "/files/list_folder/get_latest_cursor"
-	url := BaseURL + urlPath
+	url := c.baseURL() + urlPath
 	c.Logger.Debug("client.GetLatestCursor: " + url)
 
-	req, err := newJSONRequest("POST", url, params, Header{
+	req, err := c.newJSONRequest(ctx, "POST", url, params, Header{
 		"Content-Type", "application/json",
 	})
 	if err != nil {
@@ -92,15 +175,18 @@ func (c *Client) GetLatestCursor(filePath string) (string, error) {
 }
 
 func (c *Client) ListFolder(folderPath, cursor string) (*Folder, error) {
-	if _, found := strings.CutPrefix(folderPath, "/"); !found {
-		folderPath = RootFolder + folderPath
-	}
+	return c.ListFolderContext(context.Background(), folderPath, cursor)
+}
+
+func (c *Client) ListFolderContext(ctx context.Context, folderPath, cursor string) (*Folder, error) {
+	folderPath = c.ResolvePath(folderPath)
 
 	urlPath := "/files/list_folder"
 	params := map[string]any{
 		"path":      folderPath,
 		"recursive": true,
 	}
+	c.includePropertyGroups(params)
 
 	if len(cursor) != 0 {
 		urlPath += "/continue"
@@ -109,10 +195,10 @@ func (c *Client) ListFolder(folderPath, cursor string) (*Folder, error) {
 		}
 	}
 
-	url := BaseURL + urlPath
+	url := c.baseURL() + urlPath
 	c.Logger.Debug("client.ListFolder: " + url)
 
-	req, err := newJSONRequest("POST", url, params, Header{
+	req, err := c.newJSONRequest(ctx, "POST", url, params, Header{
 		"Content-Type", "application/json",
 	})
 	if err != nil {
@@ -120,138 +206,269 @@ func (c *Client) ListFolder(folderPath, cursor string) (*Folder, error) {
 	}
 
 	var folder Folder
-	if err := c.doRequest(req, urlPath, &cursor); err != nil {
+	if err := c.doRequest(req, urlPath, &folder); err != nil {
 		return nil, err
 	}
 
 	return &folder, nil
 }
 
-func (c *Client) Download(filePath string) (io.Reader, error) {
-	if _, found := strings.CutPrefix(filePath, "/"); !found {
-		filePath = RootFolder + filePath
+func (c *Client) ListFolderAll(folderPath, cursor string) (*Folder, error) {
+	return c.ListFolderAllContext(context.Background(), folderPath, cursor)
+}
+
+func (c *Client) ListFolderAllContext(ctx context.Context, folderPath, cursor string) (*Folder, error) {
+	folder, err := c.ListFolderContext(ctx, folderPath, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// follow the cursor until every page has been read
+	for folder.HasMore {
+		next, err := c.ListFolderContext(ctx, "", folder.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("error continuing from cursor %s: %w", folder.Cursor, err)
+		}
+
+		folder.Entries = append(folder.Entries, next.Entries...)
+		folder.Cursor = next.Cursor
+		folder.HasMore = next.HasMore
 	}
 
-	// JSON-encode params
+	return folder, nil
+}
+
+// ListFolderLongpoll blocks until files change under cursor or the timeout
+// elapses. Dropbox only accepts timeouts between 30 and 480 seconds, so
+// shorter ones, including zero, are raised to 30 seconds and longer ones
+// lowered to 480.
+func (c *Client) ListFolderLongpoll(cursor string, timeout time.Duration) (*LongpollResult, error) {
+	return c.ListFolderLongpollContext(context.Background(), cursor, timeout)
+}
+
+func (c *Client) ListFolderLongpollContext(ctx context.Context, cursor string, timeout time.Duration) (*LongpollResult, error) {
+	timeout = min(max(timeout, minLongpollTimeout), maxLongpollTimeout)
+
 	params := map[string]any{
-		"path": filePath,
+		"cursor":  cursor,
+		"timeout": int(timeout.Seconds()),
 	}
+	urlPath := "/files/list_folder/longpoll"
+	url := c.notifyURL() + urlPath
+	c.Logger.Debug("client.ListFolderLongpoll: " + url)
 
-	buff := &bytes.Buffer{}
-	encoder := json.NewEncoder(buff)
-	if err := encoder.Encode(params); err != nil {
-		return nil, fmt.Errorf("error encoding request argument: %w", err)
+	req, err := newJSONRequest(ctx, "POST", url, params, Header{
+		"Content-Type", "application/json",
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// then encode JSON into the URL
-	query := url.Values{"arg": []string{buff.String()}}
-	url := BaseContentURL + "/files/download?" + strings.TrimSpace(query.Encode())
-	c.Logger.Debug("client.Download: " + url)
+	// longpoll is unauthenticated, so skip the OAuth2 client; the cursor
+	// already knows its path root
+	resp, err := c.httpClient(http.DefaultClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request to %s: %w", urlPath, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newClientErr(resp, urlPath)
+	}
+	defer resp.Body.Close()
+
+	var result LongpollResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error parsing %s response body: %w", urlPath, err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) Download(filePath string) (*DownloadResult, error) {
+	return c.DownloadContext(context.Background(), filePath)
+}
+
+func (c *Client) DownloadContext(ctx context.Context, filePath string) (*DownloadResult, error) {
+	filePath = c.ResolvePath(filePath)
+
+	params := map[string]any{
+		"path": filePath,
+	}
 
-	// do request
-	req, err := http.NewRequest("POST", url, nil)
+	urlPath := "/files/download"
+	req, err := c.newContentRequest(ctx, urlPath, params, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error forming http request: %w", err)
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/octet-stream; charset=utf-8")
+	c.Logger.Debug("client.Download: " + redactURL(req.URL))
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.send(req, urlPath)
 	if err != nil {
-		return nil, fmt.Errorf("error making request to /files/download: %w", err)
+		return nil, fmt.Errorf("error downloading %s: %w", filePath, err)
 	}
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		// happy path
-		return resp.Body, nil
+	result := &DownloadResult{Body: resp.Body}
+	if err := json.Unmarshal([]byte(resp.Header.Get("Dropbox-API-Result")), &result.File); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error parsing %s result header: %w", urlPath, err)
 	}
+	result.File.Tag = TagFile
 
-	// handle error responses
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, &ClientErr{
-			StatusCode: resp.StatusCode,
-			Path:       filePath,
-			Cause:      fmt.Errorf("error reading response: %w", err),
+	// verify the body against the metadata's content hash as it's read
+	if result.File.ContentHash != "" {
+		result.Body = &verifyingReader{
+			r:        resp.Body,
+			hash:     NewContentHash(),
+			expected: result.File.ContentHash,
 		}
 	}
 
-	return nil, &ClientErr{
-		StatusCode: resp.StatusCode,
-		Path:       filePath,
-		Cause:      errors.New(string(body)),
-	}
+	return result, nil
 }
 
 func (c *Client) Upload(filePath string, r io.Reader) error {
-	if _, found := strings.CutPrefix(filePath, "/"); !found {
-		filePath = RootFolder + filePath
-	}
+	return c.UploadContext(context.Background(), filePath, r)
+}
 
-	// JSON-encode params
-	params := map[string]any{
-		"path": filePath,
-		"mode": "overwrite",
-	}
+func (c *Client) UploadContext(ctx context.Context, filePath string, r io.Reader) error {
+	_, err := c.UploadWithOptionsContext(ctx, filePath, r, UploadOptions{})
+	return err
+}
 
-	buff := &bytes.Buffer{}
-	encoder := json.NewEncoder(buff)
-	if err := encoder.Encode(params); err != nil {
-		return fmt.Errorf("error encoding request argument: %w", err)
+func (c *Client) UploadWithOptions(filePath string, r io.Reader, opts UploadOptions) (*FileMetadata, error) {
+	return c.UploadWithOptionsContext(context.Background(), filePath, r, opts)
+}
+
+func (c *Client) UploadWithOptionsContext(ctx context.Context, filePath string, r io.Reader, opts UploadOptions) (*FileMetadata, error) {
+	filePath = c.ResolvePath(filePath)
+
+	// buffer up to the threshold to decide between a single request and an
+	// upload session
+	threshold := c.uploadSessionThreshold()
+	head := &bytes.Buffer{}
+	if _, err := io.CopyN(head, r, threshold+1); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error reading upload body: %w", err)
+	}
+	if int64(head.Len()) > threshold {
+		return c.uploadSession(ctx, filePath, io.MultiReader(head, r), opts)
 	}
 
-	// then encode JSON into the URL
-	query := url.Values{"arg": []string{buff.String()}}
-	url := BaseContentURL + "/files/upload?" + strings.TrimSpace(query.Encode())
-	c.Logger.Debug("client.Upload: " + url)
+	params := opts.commitInfo(filePath)
 
-	// do request
-	req, err := http.NewRequest("POST", url, r)
+	urlPath := "/files/upload"
+	req, err := c.newContentRequest(ctx, urlPath, params, head)
 	if err != nil {
-		return fmt.Errorf("error forming http request: %w", err)
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
+	c.Logger.Debug("client.Upload: " + redactURL(req.URL))
 
-	resp, err := c.HTTPClient.Do(req)
+	// a replayed autorename upload could create a second copy
+	resp, err := c.sendRetrying(req, urlPath, !opts.Autorename)
 	if err != nil {
-		return fmt.Errorf("error calling upload: %w", err)
-	}
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		// happy path
-		return nil
+		return nil, uploadErr(filePath, err)
 	}
+	defer resp.Body.Close()
 
-	// handle error responses
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return &ClientErr{
-			StatusCode: resp.StatusCode,
-			Path:       filePath,
-			Cause:      fmt.Errorf("error reading response: %w", err),
-		}
+	var file FileMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return nil, fmt.Errorf("error parsing %s response body: %w", urlPath, err)
 	}
 
-	return &ClientErr{
-		StatusCode: resp.StatusCode,
-		Path:       filePath,
-		Cause:      errors.New(string(body)),
+	return &file, nil
+}
+
+func uploadErr(filePath string, err error) error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Is(ErrConflict) {
+		return &ConflictError{
+			Path:    filePath,
+			Summary: apiErr.Summary,
+			Cause:   err,
+		}
 	}
 
+	return fmt.Errorf("error uploading %s: %w", filePath, err)
 }
 
 func (c *Client) doRequest(req *http.Request, path string, v any) error {
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.send(req, path)
 	if err != nil {
-		return fmt.Errorf("error making request to: %s: %w", path, err)
+		return err
 	}
+	defer resp.Body.Close()
 
-	// happy path
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
-			return fmt.Errorf("error parsing get_metadata response body: %w", err)
+	// some endpoints have no result
+	if v == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error parsing %s response body: %w", path, err)
+	}
+
+	return nil
+}
+
+// send does the request, retrying according to the client's retry policy,
+// and returns the first successful response. Any other response is returned
+// as a *ClientErr.
+func (c *Client) send(req *http.Request, path string) (*http.Response, error) {
+	return c.sendRetrying(req, path, idempotentEndpoints[path])
+}
+
+// sendRetrying is send for callers that know better than idempotentEndpoints
+// whether their request can be replayed.
+func (c *Client) sendRetrying(req *http.Request, path string, idempotent bool) (*http.Response, error) {
+	policy := c.retryPolicy()
+	client := c.httpClient(c.HTTPClient)
+
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			// happy path
+			return resp, nil
 		}
 
-		return nil
+		retry, serverDelay := shouldRetry(req, idempotent, resp, err)
+		if !retry || attempt >= policy.MaxAttempts {
+			if err != nil {
+				return nil, fmt.Errorf("error making request to %s: %w", path, err)
+			}
+
+			return nil, newClientErr(resp, path)
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			resp.Body.Close()
+		}
+
+		delay := policy.backoff(attempt, serverDelay)
+		c.Logger.Warn(fmt.Sprintf("client.send: retrying %s in %s (attempt %d of %d): %s",
+			path, delay, attempt+1, policy.MaxAttempts, reason,
+		))
+
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		// rewind the body for the next attempt
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("error rewinding request body: %w", err)
+			}
+			req.Body = body
+		}
 	}
+}
+
+func newClientErr(resp *http.Response, path string) error {
+	defer resp.Body.Close()
 
 	// handle error response
 	body, err := io.ReadAll(resp.Body)
@@ -266,11 +483,73 @@ func (c *Client) doRequest(req *http.Request, path string, v any) error {
 	return &ClientErr{
 		StatusCode: resp.StatusCode,
 		Path:       path,
-		Cause:      errors.New(string(body)),
+		Cause:      parseError(body),
+	}
+}
+
+// rpc calls an RPC endpoint, which takes its argument and returns its result
+// as JSON in the body.
+func (c *Client) rpc(ctx context.Context, urlPath string, params, v any) error {
+	// endpoints without arguments still expect a JSON body
+	if params == nil {
+		params = json.RawMessage("null")
+	}
+
+	url := c.baseURL() + urlPath
+	c.Logger.Debug("client.rpc: " + url)
+
+	req, err := c.newJSONRequest(ctx, "POST", url, params, Header{
+		"Content-Type", "application/json",
+	})
+	if err != nil {
+		return err
 	}
+
+	return c.doRequest(req, urlPath, v)
+}
+
+// newContentRequest forms a request to the content endpoints, which take
+// their JSON-encoded argument in the URL rather than the body.
+func (c *Client) newContentRequest(ctx context.Context, urlPath string, arg any, body io.Reader) (*http.Request, error) {
+	// JSON-encode params
+	buff := &bytes.Buffer{}
+	encoder := json.NewEncoder(buff)
+	if err := encoder.Encode(arg); err != nil {
+		return nil, fmt.Errorf("error encoding request argument: %w", err)
+	}
+
+	// then encode JSON into the URL
+	query := url.Values{"arg": []string{strings.TrimSpace(buff.String())}}
+	url := c.contentURL() + urlPath + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, fmt.Errorf("error forming http request: %w", err)
+	}
+
+	if err := c.setTeamHeaders(req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// newJSONRequest forms a request to an authenticated endpoint, which also
+// takes the client's path root and team member selection.
+func (c *Client) newJSONRequest(ctx context.Context, method, url string, v any, headers ...Header) (*http.Request, error) {
+	req, err := newJSONRequest(ctx, method, url, v, headers...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.setTeamHeaders(req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
 }
 
-func newJSONRequest(method, url string, v any, headers ...Header) (*http.Request, error) {
+func newJSONRequest(ctx context.Context, method, url string, v any, headers ...Header) (*http.Request, error) {
 	var body io.ReadWriter
 	if v != nil {
 		body = &bytes.Buffer{}
@@ -280,7 +559,7 @@ func newJSONRequest(method, url string, v any, headers ...Header) (*http.Request
 		}
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("error forming http request: %w", err)
 	}
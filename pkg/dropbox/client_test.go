@@ -0,0 +1,277 @@
+package dropbox_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox/dropboxtest"
+)
+
+// countRequests counts the client's requests to endpoint.
+func countRequests(c *dropbox.Client, endpoint string) *atomic.Int32 {
+	var n atomic.Int32
+	c.Use(func(next http.RoundTripper) http.RoundTripper {
+		return dropbox.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, endpoint) {
+				n.Add(1)
+			}
+			return next.RoundTrip(req)
+		})
+	})
+
+	return &n
+}
+
+func TestListFolderAllPagination(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+	server.PageSize = 2
+
+	for i := range 5 {
+		server.Put(fmt.Sprintf("folder/%d.txt", i), []byte{byte(i)})
+	}
+
+	ctx := context.Background()
+	client := server.Client(nil)
+	continues := countRequests(client, "/files/list_folder/continue")
+
+	folder, err := client.ListFolderAllContext(ctx, "folder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the folder itself and its 5 files, in pages of 2
+	if len(folder.Entries) != 6 {
+		t.Errorf("got %d entries, want 6", len(folder.Entries))
+	}
+	if folder.HasMore {
+		t.Error("HasMore is set after listing everything")
+	}
+	if got := continues.Load(); got != 2 {
+		t.Errorf("got %d continue requests, want 2", got)
+	}
+
+	server.Put("folder/5.txt", []byte{5})
+	server.Put("elsewhere.txt", nil)
+
+	delta, err := client.ListFolderAllContext(ctx, "folder", folder.Cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delta.Entries) != 1 || delta.Entries[0].Base().Name != "5.txt" {
+		t.Errorf("got delta %v, want only 5.txt", delta.Entries)
+	}
+}
+
+func TestListFolderCursorReset(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	server.Put("folder/a.txt", nil)
+
+	ctx := context.Background()
+	client := server.Client(nil)
+
+	folder, err := client.ListFolderAllContext(ctx, "folder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.ResetCursors()
+
+	_, err = client.ListFolderAllContext(ctx, "folder", folder.Cursor)
+	if !dropbox.IsCursorReset(err) {
+		t.Fatalf("got %v, want a cursor reset", err)
+	}
+
+	// listing from scratch works again
+	if _, err := client.ListFolderAllContext(ctx, "folder", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadWriteModeUpdate(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	original := server.Put("a.txt", []byte("original"))
+
+	ctx := context.Background()
+	client := server.Client(nil)
+
+	updated, err := client.UploadWithOptionsContext(ctx, "a.txt", strings.NewReader("updated"), dropbox.UploadOptions{
+		Mode: dropbox.WriteModeUpdate(original.Rev),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Rev == original.Rev {
+		t.Errorf("rev didn't change from %s", original.Rev)
+	}
+
+	// the file has moved on from original.Rev
+	_, err = client.UploadWithOptionsContext(ctx, "a.txt", strings.NewReader("stale"), dropbox.UploadOptions{
+		Mode: dropbox.WriteModeUpdate(original.Rev),
+	})
+	var conflict *dropbox.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("got %v, want a *dropbox.ConflictError", err)
+	}
+	if !dropbox.IsConflict(err) {
+		t.Errorf("IsConflict(%v) = false", err)
+	}
+
+	content, _ := server.Content("a.txt")
+	if string(content) != "updated" {
+		t.Errorf("content = %q, want %q", content, "updated")
+	}
+}
+
+func TestDownloadVerifiesContentHash(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	server.Put("a.txt", []byte("the real content"))
+
+	ctx := context.Background()
+	client := server.Client(nil)
+
+	result, err := client.DownloadContext(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(result.Body)
+	result.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "the real content" {
+		t.Errorf("content = %q", content)
+	}
+
+	// corrupt the body on its way back
+	client.Use(func(next http.RoundTripper) http.RoundTripper {
+		return dropbox.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err == nil {
+				resp.Body.Close()
+				resp.Body = io.NopCloser(bytes.NewReader([]byte("the fake content")))
+			}
+			return resp, err
+		})
+	})
+
+	result, err = client.DownloadContext(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Body.Close()
+
+	if _, err := io.ReadAll(result.Body); !errors.Is(err, dropbox.ErrContentHashMismatch) {
+		t.Errorf("got %v, want dropbox.ErrContentHashMismatch", err)
+	}
+}
+
+func TestListFolderLongpollTimeout(t *testing.T) {
+	var sent int
+	client := &dropbox.Client{
+		HTTPClient: http.DefaultClient,
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	// answer longpolls without going anywhere
+	client.Use(func(next http.RoundTripper) http.RoundTripper {
+		return dropbox.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var params struct {
+				Timeout int `json:"timeout"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+				return nil, err
+			}
+			sent = params.Timeout
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"changes":false}`)),
+				Request:    req,
+			}, nil
+		})
+	})
+
+	tests := []struct {
+		timeout time.Duration
+		want    int
+	}{
+		{0, 30},
+		{10 * time.Second, 30},
+		{90 * time.Second, 90},
+		{8 * time.Minute, 480},
+		{time.Hour, 480},
+	}
+	for _, tt := range tests {
+		if _, err := client.ListFolderLongpollContext(context.Background(), "cursor", tt.timeout); err != nil {
+			t.Fatal(err)
+		}
+		if sent != tt.want {
+			t.Errorf("timeout %s sent as %d seconds, want %d", tt.timeout, sent, tt.want)
+		}
+	}
+}
+
+func TestUploadSessionRetriesAppends(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	client := server.Client(nil)
+	client.UploadSessionThreshold = 4
+	client.UploadChunkSize = 4
+	client.Retry = &dropbox.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	// the first append fails before it gets anywhere; the second lands, but
+	// its response is lost
+	var appends int
+	client.Use(func(next http.RoundTripper) http.RoundTripper {
+		return dropbox.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "/files/upload_session/append_v2") {
+				return next.RoundTrip(req)
+			}
+
+			appends++
+			switch appends {
+			case 1:
+				return nil, errors.New("connection reset")
+			case 2:
+				resp, err := next.RoundTrip(req)
+				if err == nil {
+					resp.Body.Close()
+				}
+				return nil, errors.New("connection reset")
+			default:
+				return next.RoundTrip(req)
+			}
+		})
+	})
+
+	const content = "abcdefghijklmnopqrst"
+	if _, err := client.UploadWithOptionsContext(context.Background(), "a.txt", strings.NewReader(content), dropbox.UploadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if appends < 3 {
+		t.Errorf("got %d appends, want the failed ones to be retried", appends)
+	}
+
+	got, _ := server.Content("a.txt")
+	if string(got) != content {
+		t.Errorf("content = %q, want %q", got, content)
+	}
+}
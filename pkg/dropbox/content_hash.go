@@ -0,0 +1,111 @@
+package dropbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// ContentHashBlockSize is the size of the blocks Dropbox hashes individually
+// when computing a file's content hash.
+const ContentHashBlockSize = 4 << 20
+
+var ErrContentHashMismatch = errors.New("content hash mismatch")
+
+// contentHash implements Dropbox's content hash: the SHA-256 of the
+// concatenated SHA-256s of each 4 MiB block of the file.
+// See https://www.dropbox.com/developers/reference/content-hash
+type contentHash struct {
+	blockSums []byte
+	block     hash.Hash
+	blockLen  int
+}
+
+// NewContentHash returns a hash.Hash computing Dropbox content hashes, which
+// can be compared with FileMetadata.ContentHash once hex-encoded.
+func NewContentHash() hash.Hash {
+	return &contentHash{
+		block: sha256.New(),
+	}
+}
+
+func (h *contentHash) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), ContentHashBlockSize-h.blockLen)
+		h.block.Write(p[:n])
+		h.blockLen += n
+		written += n
+		p = p[n:]
+
+		if h.blockLen == ContentHashBlockSize {
+			h.blockSums = h.block.Sum(h.blockSums)
+			h.block.Reset()
+			h.blockLen = 0
+		}
+	}
+
+	return written, nil
+}
+
+func (h *contentHash) Sum(b []byte) []byte {
+	sums := h.blockSums
+	if h.blockLen > 0 {
+		sums = h.block.Sum(sums[:len(sums):len(sums)])
+	}
+
+	sum := sha256.Sum256(sums)
+	return append(b, sum[:]...)
+}
+
+func (h *contentHash) Reset() {
+	h.blockSums = h.blockSums[:0]
+	h.block.Reset()
+	h.blockLen = 0
+}
+
+func (h *contentHash) Size() int {
+	return sha256.Size
+}
+
+func (h *contentHash) BlockSize() int {
+	return sha256.BlockSize
+}
+
+// ContentHash reads r to the end and returns its hex-encoded content hash.
+func ContentHash(r io.Reader) (string, error) {
+	h := NewContentHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyingReader hashes everything read through it and fails at EOF if the
+// content hash doesn't match the expected one.
+type verifyingReader struct {
+	r        io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.hash.Write(p[:n])
+
+	if errors.Is(err, io.EOF) {
+		if actual := hex.EncodeToString(v.hash.Sum(nil)); actual != v.expected {
+			return n, fmt.Errorf("%w: expected %s, got %s", ErrContentHashMismatch, v.expected, actual)
+		}
+	}
+
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.r.Close()
+}
@@ -0,0 +1,104 @@
+package dropbox_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+)
+
+// blockwise hashes content the way Dropbox describes it, block by block, to
+// check the streaming implementation against.
+func blockwise(content []byte) string {
+	var sums []byte
+	for len(content) > 0 {
+		n := min(len(content), dropbox.ContentHashBlockSize)
+		sum := sha256.Sum256(content[:n])
+		sums = append(sums, sum[:]...)
+		content = content[n:]
+	}
+
+	sum := sha256.Sum256(sums)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestContentHash(t *testing.T) {
+	content := make([]byte, 2*dropbox.ContentHashBlockSize+3)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+
+	tests := []struct {
+		name string
+		size int
+		want string
+	}{
+		// the SHA-256 of nothing, since there are no blocks
+		{"empty", 0, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"one byte", 1, ""},
+		{"one block less a byte", dropbox.ContentHashBlockSize - 1, ""},
+		{"exactly one block", dropbox.ContentHashBlockSize, ""},
+		{"one block and a byte", dropbox.ContentHashBlockSize + 1, ""},
+		{"several blocks", len(content), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == "" {
+				want = blockwise(content[:tt.size])
+			}
+
+			got, err := dropbox.ContentHash(bytes.NewReader(content[:tt.size]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("ContentHash = %s, want %s", got, want)
+			}
+
+			// writes that straddle block boundaries hash the same
+			h := dropbox.NewContentHash()
+			if _, err := io.CopyBuffer(h, onlyReader{bytes.NewReader(content[:tt.size])}, make([]byte, 1<<20+13)); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(h.Sum(nil)); got != want {
+				t.Errorf("hashed in odd sized writes = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// TestContentHashReference checks the example from Dropbox's content hash
+// reference, https://www.dropbox.com/developers/reference/content-hash. The
+// image isn't checked in; download it from
+// https://www.dropbox.com/static/images/developers/milky-way-nasa.jpg to
+// testdata to run it.
+func TestContentHashReference(t *testing.T) {
+	f, err := os.Open("testdata/milky-way-nasa.jpg")
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("testdata/milky-way-nasa.jpg is missing")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := dropbox.ContentHash(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "485291fa0ee50c016982abbfa943957bcd231aae0492ccbaa22c58e3997b35e0"; got != want {
+		t.Errorf("ContentHash = %s, want %s", got, want)
+	}
+}
+
+// onlyReader hides everything but Read, so io.CopyBuffer uses its buffer.
+type onlyReader struct {
+	io.Reader
+}
@@ -0,0 +1,1010 @@
+// Package dropboxtest provides an in-process fake of the Dropbox API for
+// exercising dropbox.Client, and everything built on it, offline.
+package dropboxtest
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+)
+
+// Account is the account ID the fake reports changes for.
+const Account = "dbid:dropboxtest"
+
+// Server fakes get_metadata, list_folder (with continue, get_latest_cursor
+// and longpoll), download, download_zip, export, upload (and upload
+// sessions), get_current_account and get_space_usage, and can deliver signed
+// webhook notifications. Every change is recorded in a journal, which cursors
+// are positions in, so deltas behave like Dropbox's.
+type Server struct {
+	*httptest.Server
+
+	// AppSecret signs webhook notifications
+	AppSecret string
+	// WebhookURL is where Notify delivers notifications
+	WebhookURL string
+	// Allocated is the space reported by get_space_usage
+	Allocated uint64
+	// MaxZipFiles is how many files download_zip allows before failing with
+	// too_many_files; zero is unlimited
+	MaxZipFiles int
+	// PageSize limits the entries in each list_folder page; zero is
+	// unlimited
+	PageSize int
+
+	mu      sync.Mutex
+	entries map[string]dropbox.Metadata
+	content map[string][]byte
+	// what files that can only be exported export as, by format
+	exports map[string]map[string][]byte
+	journal []dropbox.Metadata
+	revs    int
+	// cursors from an earlier epoch have been reset
+	epoch int
+	// closed and replaced whenever the journal grows
+	changed chan struct{}
+	// content uploaded to each open upload session
+	sessions   map[string][]byte
+	sessionIDs int
+}
+
+type cursor struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive"`
+	Epoch     int    `json:"epoch"`
+	// the delta being listed is the journal between From and To; a To of -1
+	// is wherever the journal's at when the cursor's next used
+	From   int `json:"from"`
+	To     int `json:"to"`
+	Offset int `json:"offset"`
+	// Initial listings leave out deleted entries
+	Initial bool `json:"initial"`
+}
+
+type sessionCursor struct {
+	SessionID string `json:"session_id"`
+	Offset    int64  `json:"offset"`
+}
+
+// commitInfo is where and how upload and upload_session/finish write a file.
+type commitInfo struct {
+	Path       string          `json:"path"`
+	Mode       json.RawMessage `json:"mode"`
+	Autorename bool            `json:"autorename"`
+}
+
+func NewServer() *Server {
+	s := &Server{
+		entries:  make(map[string]dropbox.Metadata),
+		content:  make(map[string][]byte),
+		exports:  make(map[string]map[string][]byte),
+		changed:  make(chan struct{}),
+		sessions: make(map[string][]byte),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /2/files/get_metadata", s.getMetadata)
+	mux.HandleFunc("POST /2/files/list_folder", s.listFolder)
+	mux.HandleFunc("POST /2/files/list_folder/continue", s.listFolderContinue)
+	mux.HandleFunc("POST /2/files/list_folder/get_latest_cursor", s.getLatestCursor)
+	mux.HandleFunc("POST /2/files/list_folder/longpoll", s.longpoll)
+	mux.HandleFunc("POST /2/files/download", s.download)
+	mux.HandleFunc("POST /2/files/download_zip", s.downloadZip)
+	mux.HandleFunc("POST /2/files/export", s.export)
+	mux.HandleFunc("POST /2/files/upload", s.upload)
+	mux.HandleFunc("POST /2/files/upload_session/start", s.uploadSessionStart)
+	mux.HandleFunc("POST /2/files/upload_session/append_v2", s.uploadSessionAppend)
+	mux.HandleFunc("POST /2/files/upload_session/finish", s.uploadSessionFinish)
+	mux.HandleFunc("POST /2/users/get_current_account", s.getCurrentAccount)
+	mux.HandleFunc("POST /2/users/get_space_usage", s.getSpaceUsage)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Client returns a client pointed at the fake. Retries are disabled so
+// failures surface straight away.
+func (s *Server) Client(logger *slog.Logger) *dropbox.Client {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	return &dropbox.Client{
+		HTTPClient: s.Server.Client(),
+		Logger:     logger,
+		BaseURL:    s.URL + "/2",
+		NotifyURL:  s.URL + "/2",
+		ContentURL: s.URL + "/2",
+		Retry:      &dropbox.RetryPolicy{MaxAttempts: 1},
+	}
+}
+
+// Put writes a file as if someone edited it in Dropbox, creating any missing
+// parent folders. Relative paths are under dropbox.DefaultRootFolder.
+func (s *Server) Put(filePath string, content []byte) *dropbox.FileMetadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.put(resolve(filePath), content)
+}
+
+// PutNative writes a file that can't be downloaded, only exported, like a
+// Google Sheet. exports is its content in each export format, and exportAs
+// the default format.
+func (s *Server) PutNative(filePath string, exports map[string][]byte, exportAs string) *dropbox.FileMetadata {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file := s.put(resolve(filePath), nil)
+	file.IsDownloadable = false
+	file.ExportInfo = &dropbox.ExportInfo{ExportAs: exportAs}
+	for format := range exports {
+		file.ExportInfo.ExportOptions = append(file.ExportInfo.ExportOptions, format)
+	}
+	slices.Sort(file.ExportInfo.ExportOptions)
+	s.exports[file.PathLower] = exports
+
+	return file
+}
+
+// Delete removes a file or folder, and everything under it, as if someone
+// deleted it in Dropbox. It reports whether anything was deleted.
+func (s *Server) Delete(filePath string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lower := strings.ToLower(resolve(filePath))
+	var paths []string
+	for p := range s.entries {
+		if under(p, lower, true) {
+			paths = append(paths, p)
+		}
+	}
+	// parents before their children
+	slices.Sort(paths)
+
+	for _, p := range paths {
+		m := s.entries[p]
+		delete(s.entries, p)
+		delete(s.content, p)
+		delete(s.exports, p)
+		s.record(&dropbox.DeletedMetadata{BaseMetadata: dropbox.BaseMetadata{
+			Tag:         dropbox.TagDeleted,
+			Name:        m.Base().Name,
+			PathDisplay: m.Base().PathDisplay,
+			PathLower:   p,
+		}})
+	}
+
+	return len(paths) > 0
+}
+
+// ResetCursors makes every cursor handed out so far fail with a reset error,
+// as Dropbox does now and then.
+func (s *Server) ResetCursors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.epoch++
+}
+
+// Content returns the content of the file at filePath.
+func (s *Server) Content(filePath string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	content, ok := s.content[strings.ToLower(resolve(filePath))]
+	return bytes.Clone(content), ok
+}
+
+// Metadata returns the current metadata of the entry at filePath.
+func (s *Server) Metadata(filePath string) (dropbox.Metadata, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.entries[strings.ToLower(resolve(filePath))]
+	return m, ok
+}
+
+// Notify delivers a webhook notification for Account to WebhookURL, signed
+// with AppSecret like Dropbox does, and waits for the response.
+func (s *Server) Notify(ctx context.Context) error {
+	body, err := json.Marshal(map[string]any{
+		"list_folder": map[string]any{
+			"accounts": []string{Account},
+		},
+		"delta": map[string]any{
+			"users": []int{},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.AppSecret))
+	mac.Write(body)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Dropbox-Signature", hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error delivering webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook responded %s: %s", resp.Status, b)
+	}
+
+	return nil
+}
+
+func (s *Server) put(filePath string, content []byte) *dropbox.FileMetadata {
+	// create missing parents, outermost first
+	var missing []string
+	for dir := path.Dir(filePath); dir != "/"; dir = path.Dir(dir) {
+		if _, ok := s.entries[strings.ToLower(dir)]; ok {
+			break
+		}
+		missing = append(missing, dir)
+	}
+	for _, dir := range slices.Backward(missing) {
+		lower := strings.ToLower(dir)
+		folder := &dropbox.FolderMetadata{
+			BaseMetadata: dropbox.BaseMetadata{
+				Tag:         dropbox.TagFolder,
+				Name:        path.Base(dir),
+				PathDisplay: dir,
+				PathLower:   lower,
+			},
+			ID: "id:" + lower,
+		}
+		s.entries[lower] = folder
+		s.record(folder)
+	}
+
+	s.revs++
+	hash, _ := dropbox.ContentHash(bytes.NewReader(content))
+	now := time.Now().UTC().Truncate(time.Second)
+	lower := strings.ToLower(filePath)
+
+	file := &dropbox.FileMetadata{
+		BaseMetadata: dropbox.BaseMetadata{
+			Tag:         dropbox.TagFile,
+			Name:        path.Base(filePath),
+			PathDisplay: filePath,
+			PathLower:   lower,
+		},
+		ClientModified: now,
+		ServerModified: now,
+		ContentHash:    hash,
+		ID:             "id:" + lower,
+		IsDownloadable: true,
+		Rev:            fmt.Sprintf("%09x", s.revs),
+		Size:           len(content),
+	}
+	s.entries[lower] = file
+	s.content[lower] = bytes.Clone(content)
+	delete(s.exports, lower)
+	s.record(file)
+
+	return file
+}
+
+func (s *Server) record(m dropbox.Metadata) {
+	s.journal = append(s.journal, m)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// delta returns the latest state of every entry under the cursor's path that
+// changed between its positions in the journal, in the order they last
+// changed. Like Dropbox, an entry that was deleted and then created again is
+// listed as deleted first, unless the listing is an initial one.
+func (s *Server) delta(c cursor) []dropbox.Metadata {
+	latest := make(map[string]int)
+	deleted := make(map[string]int)
+	for i := c.From; i < c.To; i++ {
+		lower := s.journal[i].Base().PathLower
+		if !under(lower, c.Path, c.Recursive) {
+			continue
+		}
+		latest[lower] = i
+		if _, ok := s.journal[i].(*dropbox.DeletedMetadata); ok {
+			deleted[lower] = i
+		}
+	}
+
+	var positions []int
+	for lower, i := range latest {
+		_, isDeleted := s.journal[i].(*dropbox.DeletedMetadata)
+		if c.Initial && isDeleted {
+			continue
+		}
+		positions = append(positions, i)
+		if d, ok := deleted[lower]; ok && !isDeleted && !c.Initial {
+			positions = append(positions, d)
+		}
+	}
+	slices.Sort(positions)
+
+	entries := make([]dropbox.Metadata, 0, len(positions))
+	for _, i := range positions {
+		entries = append(entries, s.journal[i])
+	}
+
+	return entries
+}
+
+func (s *Server) page(w http.ResponseWriter, c cursor) {
+	if c.To < 0 {
+		c.To = len(s.journal)
+	}
+	if c.Epoch != s.epoch || c.From > c.To || c.To > len(s.journal) {
+		writeError(w, http.StatusConflict, "reset/", map[string]any{".tag": "reset"})
+		return
+	}
+
+	entries := s.delta(c)
+	if c.Offset > len(entries) {
+		c.Offset = len(entries)
+	}
+	entries = entries[c.Offset:]
+
+	hasMore := s.PageSize > 0 && len(entries) > s.PageSize
+	next := cursor{Path: c.Path, Recursive: c.Recursive, Epoch: c.Epoch, From: c.To, To: -1}
+	if hasMore {
+		entries = entries[:s.PageSize]
+		next = c
+		next.Offset += s.PageSize
+	}
+
+	writeJSON(w, map[string]any{
+		"entries":  entries,
+		"cursor":   encodeCursor(next),
+		"has_more": hasMore,
+	})
+}
+
+func (s *Server) getMetadata(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if !readJSON(w, r, &params) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.entries[strings.ToLower(params.Path)]
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+
+	writeJSON(w, m)
+}
+
+func (s *Server) listFolder(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Path      string `json:"path"`
+		Recursive bool   `json:"recursive"`
+	}
+	if !readJSON(w, r, &params) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lower := strings.ToLower(params.Path)
+	if _, ok := s.entries[lower]; !ok && lower != "" {
+		writeNotFound(w)
+		return
+	}
+
+	s.page(w, cursor{
+		Path:      lower,
+		Recursive: params.Recursive,
+		Epoch:     s.epoch,
+		From:      0,
+		To:        len(s.journal),
+		Initial:   true,
+	})
+}
+
+func (s *Server) listFolderContinue(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Cursor string `json:"cursor"`
+	}
+	if !readJSON(w, r, &params) {
+		return
+	}
+
+	c, ok := decodeCursor(w, params.Cursor)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.page(w, c)
+}
+
+func (s *Server) getLatestCursor(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Path      string `json:"path"`
+		Recursive bool   `json:"recursive"`
+	}
+	if !readJSON(w, r, &params) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, map[string]any{
+		"cursor": encodeCursor(cursor{
+			Path:      strings.ToLower(params.Path),
+			Recursive: params.Recursive,
+			Epoch:     s.epoch,
+			From:      len(s.journal),
+			To:        -1,
+		}),
+	})
+}
+
+func (s *Server) longpoll(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Cursor  string `json:"cursor"`
+		Timeout int    `json:"timeout"`
+	}
+	if !readJSON(w, r, &params) {
+		return
+	}
+
+	c, ok := decodeCursor(w, params.Cursor)
+	if !ok {
+		return
+	}
+
+	timeout := time.After(time.Duration(params.Timeout) * time.Second)
+	for {
+		s.mu.Lock()
+		if c.Epoch != s.epoch {
+			s.mu.Unlock()
+			writeError(w, http.StatusConflict, "reset/", map[string]any{".tag": "reset"})
+			return
+		}
+		c.To = len(s.journal)
+		changes := len(s.delta(c)) > 0
+		changed := s.changed
+		s.mu.Unlock()
+
+		if changes {
+			writeJSON(w, map[string]any{"changes": true})
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timeout:
+			writeJSON(w, map[string]any{"changes": false})
+			return
+		case <-changed:
+		}
+	}
+}
+
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if !readArg(w, r, &params) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lower := strings.ToLower(params.Path)
+	file, ok := s.entries[lower].(*dropbox.FileMetadata)
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+	if !file.IsDownloadable {
+		writeError(w, http.StatusConflict, "unsupported_file/", map[string]any{
+			".tag": "unsupported_file",
+		})
+		return
+	}
+
+	result, err := json.Marshal(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Dropbox-API-Result", asciiJSON(result))
+	w.Write(s.content[lower])
+}
+
+func (s *Server) downloadZip(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if !readArg(w, r, &params) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lower := strings.ToLower(params.Path)
+	folder, ok := s.entries[lower].(*dropbox.FolderMetadata)
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+
+	var paths []string
+	for p := range s.entries {
+		if under(p, lower, true) {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+
+	files := 0
+	for _, p := range paths {
+		if _, ok := s.entries[p].(*dropbox.FileMetadata); ok {
+			files++
+		}
+	}
+	if s.MaxZipFiles > 0 && files > s.MaxZipFiles {
+		writeError(w, http.StatusConflict, "too_many_files/", map[string]any{
+			".tag": "too_many_files",
+		})
+		return
+	}
+
+	result, err := json.Marshal(map[string]any{"metadata": folder})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Dropbox-API-Result", asciiJSON(result))
+
+	// entries are put under the folder's name, like Dropbox does
+	zw := zip.NewWriter(w)
+	for _, p := range paths {
+		m := s.entries[p]
+		name := path.Join(folder.Name, strings.TrimPrefix(m.Base().PathDisplay, folder.PathDisplay))
+		if _, ok := m.(*dropbox.FolderMetadata); ok {
+			zw.CreateHeader(&zip.FileHeader{Name: name + "/"})
+			continue
+		}
+
+		zf, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: m.(*dropbox.FileMetadata).ClientModified,
+		})
+		if err != nil {
+			return
+		}
+		zf.Write(s.content[p])
+	}
+	zw.Close()
+}
+
+func (s *Server) export(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Path         string `json:"path"`
+		ExportFormat string `json:"export_format"`
+	}
+	if !readArg(w, r, &params) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lower := strings.ToLower(params.Path)
+	file, ok := s.entries[lower].(*dropbox.FileMetadata)
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+	if file.ExportInfo == nil {
+		writeError(w, http.StatusConflict, "non_exportable/", map[string]any{
+			".tag": "non_exportable",
+		})
+		return
+	}
+
+	format := params.ExportFormat
+	if format == "" {
+		format = file.ExportInfo.ExportAs
+	}
+	content, ok := s.exports[lower][format]
+	if !ok {
+		writeError(w, http.StatusConflict, "invalid_export_format/", map[string]any{
+			".tag": "invalid_export_format",
+		})
+		return
+	}
+
+	hash, _ := dropbox.ContentHash(bytes.NewReader(content))
+	result, err := json.Marshal(map[string]any{
+		"export_metadata": dropbox.ExportMetadata{
+			Name:       strings.TrimSuffix(file.Name, path.Ext(file.Name)) + "." + format,
+			Size:       len(content),
+			ExportHash: hash,
+		},
+		"file_metadata": file,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Dropbox-API-Result", asciiJSON(result))
+	w.Write(content)
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	var params commitInfo
+	if !readArg(w, r, &params) {
+		return
+	}
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commit(w, params, content)
+}
+
+func (s *Server) uploadSessionStart(w http.ResponseWriter, r *http.Request) {
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessionIDs++
+	id := fmt.Sprintf("session%d", s.sessionIDs)
+	s.sessions[id] = content
+
+	writeJSON(w, map[string]any{"session_id": id})
+}
+
+func (s *Server) uploadSessionAppend(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Cursor sessionCursor `json:"cursor"`
+	}
+	if !readArg(w, r, &params) {
+		return
+	}
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.lookupSession(w, params.Cursor, "")
+	if !ok {
+		return
+	}
+	s.sessions[params.Cursor.SessionID] = append(session, content...)
+
+	writeJSON(w, nil)
+}
+
+func (s *Server) uploadSessionFinish(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Cursor sessionCursor `json:"cursor"`
+		Commit commitInfo    `json:"commit"`
+	}
+	if !readArg(w, r, &params) {
+		return
+	}
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.lookupSession(w, params.Cursor, "lookup_failed")
+	if !ok {
+		return
+	}
+	delete(s.sessions, params.Cursor.SessionID)
+
+	s.commit(w, params.Commit, append(session, content...))
+}
+
+// lookupSession returns the content uploaded to the cursor's session so far,
+// failing like Dropbox if the cursor's offset isn't at its end. Errors are
+// nested under tag, if any, as finish does.
+func (s *Server) lookupSession(w http.ResponseWriter, c sessionCursor, tag string) ([]byte, bool) {
+	session, ok := s.sessions[c.SessionID]
+
+	var detail map[string]any
+	switch {
+	case !ok:
+		detail = map[string]any{".tag": "not_found"}
+	case c.Offset != int64(len(session)):
+		detail = map[string]any{".tag": "incorrect_offset", "correct_offset": len(session)}
+	default:
+		return session, true
+	}
+
+	summary := detail[".tag"].(string) + "/"
+	if tag != "" {
+		summary = tag + "/" + summary
+		detail = map[string]any{".tag": tag, tag: detail}
+	}
+	writeError(w, http.StatusConflict, summary, detail)
+
+	return nil, false
+}
+
+// commit writes content to the path in c, as upload and upload_session/finish
+// do. s.mu must be held.
+func (s *Server) commit(w http.ResponseWriter, c commitInfo, content []byte) {
+	var mode struct {
+		Tag    string `json:".tag"`
+		Update string `json:"update"`
+	}
+	if err := json.Unmarshal(c.Mode, &mode.Tag); err != nil && len(c.Mode) != 0 {
+		if err := json.Unmarshal(c.Mode, &mode); err != nil {
+			http.Error(w, "invalid mode: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	filePath := c.Path
+	existing, exists := s.entries[strings.ToLower(filePath)]
+	current, isFile := existing.(*dropbox.FileMetadata)
+
+	conflict := false
+	switch mode.Tag {
+	case "", "add":
+		conflict = exists
+	case "overwrite":
+		conflict = exists && !isFile
+	case "update":
+		conflict = !isFile || current.Rev != mode.Update
+	default:
+		http.Error(w, "unknown mode "+mode.Tag, http.StatusBadRequest)
+		return
+	}
+
+	// uploading identical content to a file is a no-op in Dropbox
+	if isFile && mode.Tag != "update" && bytes.Equal(s.content[current.PathLower], content) {
+		writeJSON(w, current)
+		return
+	}
+
+	if conflict && c.Autorename {
+		filePath = s.rename(filePath)
+		conflict = false
+	}
+	if conflict {
+		writeError(w, http.StatusConflict, "path/conflict/file/", map[string]any{
+			".tag": "path",
+			"reason": map[string]any{
+				".tag":     "conflict",
+				"conflict": map[string]any{".tag": "file"},
+			},
+			"upload_session_id": "",
+		})
+		return
+	}
+
+	writeJSON(w, s.put(filePath, content))
+}
+
+func (s *Server) getCurrentAccount(w http.ResponseWriter, r *http.Request) {
+	var account dropbox.FullAccount
+	account.AccountID = Account
+	account.Name.DisplayName = "Dropbox Test"
+	account.Email = "dropboxtest@example.com"
+	account.EmailVerified = true
+	account.AccountType.Tag = "basic"
+	account.RootInfo = dropbox.RootInfo{
+		Tag:             "user",
+		RootNamespaceID: "1",
+		HomeNamespaceID: "1",
+	}
+
+	writeJSON(w, &account)
+}
+
+func (s *Server) getSpaceUsage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var used uint64
+	for _, content := range s.content {
+		used += uint64(len(content))
+	}
+
+	writeJSON(w, &dropbox.SpaceUsage{
+		Used: used,
+		Allocation: dropbox.SpaceAllocation{
+			Tag:       "individual",
+			Allocated: s.Allocated,
+		},
+	})
+}
+
+// rename finds a free name for filePath like Dropbox's autorename does, e.g.
+// "ratings (1).csv".
+func (s *Server) rename(filePath string) string {
+	ext := path.Ext(filePath)
+	base := strings.TrimSuffix(filePath, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, ok := s.entries[strings.ToLower(candidate)]; !ok {
+			return candidate
+		}
+	}
+}
+
+func resolve(filePath string) string {
+	if !strings.HasPrefix(filePath, "/") {
+		filePath = dropbox.DefaultRootFolder + "/" + filePath
+	}
+
+	return path.Clean(filePath)
+}
+
+// under reports whether lower is dir itself or in it.
+func under(lower, dir string, recursive bool) bool {
+	if lower == dir {
+		return true
+	}
+	if !strings.HasPrefix(lower, dir+"/") {
+		return false
+	}
+
+	return recursive || !strings.Contains(strings.TrimPrefix(lower, dir+"/"), "/")
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(w http.ResponseWriter, s string) (cursor, bool) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil {
+		http.Error(w, "invalid cursor: "+err.Error(), http.StatusBadRequest)
+		return c, false
+	}
+
+	return c, true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// readArg reads a content endpoint's argument from the URL or, like Dropbox
+// also allows, the Dropbox-API-Arg header.
+func readArg(w http.ResponseWriter, r *http.Request, v any) bool {
+	arg := r.URL.Query().Get("arg")
+	if arg == "" {
+		arg = r.Header.Get("Dropbox-API-Arg")
+	}
+
+	if err := json.Unmarshal([]byte(arg), v); err != nil {
+		http.Error(w, "invalid arg: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, summary string, detail any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error_summary": summary + "..",
+		"error":         detail,
+	})
+}
+
+func writeNotFound(w http.ResponseWriter) {
+	writeError(w, http.StatusConflict, "path/not_found/", map[string]any{
+		".tag": "path",
+		"path": map[string]any{".tag": "not_found"},
+	})
+}
+
+// asciiJSON escapes non-ASCII characters so JSON can be sent in a header.
+func asciiJSON(b []byte) string {
+	var sb strings.Builder
+	for _, r := range string(b) {
+		if r < 0x80 {
+			sb.WriteRune(r)
+			continue
+		}
+
+		for _, u := range []rune(string(r)) {
+			if u > 0xffff {
+				// encode as a surrogate pair
+				u -= 0x10000
+				fmt.Fprintf(&sb, `\u%04x\u%04x`, 0xd800+(u>>10), 0xdc00+(u&0x3ff))
+				continue
+			}
+			fmt.Fprintf(&sb, `\u%04x`, u)
+		}
+	}
+
+	return sb.String()
+}
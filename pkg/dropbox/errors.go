@@ -0,0 +1,160 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Sentinels matched by *Error through errors.Is, e.g.
+//
+//	if errors.Is(err, dropbox.ErrNotFound) { ... }
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrConflict          = errors.New("conflict")
+	ErrCursorReset       = errors.New("cursor reset")
+	ErrInsufficientSpace = errors.New("insufficient space")
+	ErrSharedLinkExists  = errors.New("shared link already exists")
+	ErrLockConflict      = errors.New("file is locked by someone else")
+	// ErrZipTooLarge is returned by the server for folders over its zip size
+	// or file count limits; see Client.DownloadZip
+	ErrZipTooLarge = errors.New("folder is too large to zip")
+	// ErrInvalidRoot is returned when the path root doesn't match the
+	// user's, e.g. since they joined a team
+	ErrInvalidRoot = errors.New("invalid path root")
+)
+
+type LocalizedText struct {
+	Locale string `json:"locale"`
+	Text   string `json:"text"`
+}
+
+// Tags is the path of .tags through an error, e.g. ["path", "not_found"] for
+// a failed path lookup, read from the error summary.
+func (e *Error) Tags() []string {
+	summary, _, _ := strings.Cut(e.Summary, "..")
+	tags := strings.Split(strings.Trim(summary, "/"), "/")
+	if len(tags) == 1 && tags[0] == "" {
+		return nil
+	}
+
+	return tags
+}
+
+func (e *Error) HasTag(tag string) bool {
+	for _, t := range e.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *Error) Error() string {
+	if e.UserMessage != nil {
+		return e.Summary + " (" + e.UserMessage.Text + ")"
+	}
+
+	return e.Summary
+}
+
+func (e *Error) Is(target error) bool {
+	return matchTags(e.Tags(), target)
+}
+
+// BatchEntryError is the failure of a single entry of a batch operation.
+type BatchEntryError struct {
+	// Tags is the path of .tags through the failure, e.g.
+	// ["from_lookup", "not_found"]
+	Tags   []string
+	Detail json.RawMessage
+}
+
+func (e *BatchEntryError) Error() string {
+	return strings.Join(e.Tags, "/")
+}
+
+func (e *BatchEntryError) Is(target error) bool {
+	return matchTags(e.Tags, target)
+}
+
+func matchTags(tags []string, target error) bool {
+	var match []string
+	switch target {
+	case ErrNotFound:
+		match = []string{"not_found"}
+	case ErrConflict:
+		match = []string{"conflict"}
+	case ErrCursorReset:
+		match = []string{"reset"}
+	case ErrInsufficientSpace:
+		match = []string{"insufficient_space"}
+	case ErrSharedLinkExists:
+		match = []string{"shared_link_already_exists"}
+	case ErrLockConflict:
+		match = []string{"lock_conflict"}
+	case ErrZipTooLarge:
+		match = []string{"too_large", "too_many_files"}
+	case ErrInvalidRoot:
+		match = []string{"invalid_root"}
+	default:
+		return false
+	}
+
+	for _, t := range tags {
+		if slices.Contains(match, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unionTags follows the .tags of nested unions, e.g.
+// {".tag": "path", "path": {".tag": "not_found"}} is ["path", "not_found"].
+func unionTags(raw json.RawMessage) []string {
+	var tags []string
+	for {
+		var union map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &union); err != nil {
+			return tags
+		}
+
+		var tag string
+		if err := json.Unmarshal(union[".tag"], &tag); err != nil {
+			return tags
+		}
+
+		tags = append(tags, tag)
+		raw = union[tag]
+	}
+}
+
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+func IsCursorReset(err error) bool {
+	return errors.Is(err, ErrCursorReset)
+}
+
+func IsInsufficientSpace(err error) bool {
+	return errors.Is(err, ErrInsufficientSpace)
+}
+
+// parseError decodes a Dropbox error response, falling back on the raw body
+// for responses that aren't JSON, e.g. 400s and 5xxs.
+func parseError(body []byte) error {
+	var apiErr Error
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Summary == "" {
+		return errors.New(string(body))
+	}
+
+	return &apiErr
+}
@@ -0,0 +1,66 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type (
+	// ExportResult is an exported file's metadata and its content in the
+	// export format. The caller must close Body.
+	ExportResult struct {
+		File   FileMetadata   `json:"file_metadata"`
+		Export ExportMetadata `json:"export_metadata"`
+		Body   io.ReadCloser  `json:"-"`
+	}
+
+	ExportMetadata struct {
+		// Name is the exported file's name, with the export format's
+		// extension
+		Name       string `json:"name"`
+		Size       int    `json:"size"`
+		ExportHash string `json:"export_hash"`
+	}
+)
+
+// Export downloads a file that can't be downloaded as is, like a Google Sheet,
+// converted to format, one of its ExportInfo.ExportOptions. An empty format
+// is the file's ExportInfo.ExportAs.
+func (c *Client) Export(filePath, format string) (*ExportResult, error) {
+	return c.ExportContext(context.Background(), filePath, format)
+}
+
+func (c *Client) ExportContext(ctx context.Context, filePath, format string) (*ExportResult, error) {
+	filePath = c.ResolvePath(filePath)
+
+	params := map[string]any{
+		"path": filePath,
+	}
+	if format != "" {
+		params["export_format"] = format
+	}
+
+	urlPath := "/files/export"
+	req, err := c.newContentRequest(ctx, urlPath, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.Logger.Debug("client.Export: " + redactURL(req.URL))
+
+	resp, err := c.send(req, urlPath)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting %s: %w", filePath, err)
+	}
+
+	var result ExportResult
+	if err := json.Unmarshal([]byte(resp.Header.Get("Dropbox-API-Result")), &result); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error parsing %s result header: %w", urlPath, err)
+	}
+	result.File.Tag = TagFile
+	result.Body = resp.Body
+
+	return &result, nil
+}
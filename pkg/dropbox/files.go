@@ -0,0 +1,208 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Files is the part of Client the app depends on, so it can be decorated (see
+// ObservedFiles and CachingFiles) or replaced, e.g. with a client pointed at
+// dropboxtest.Server.
+type Files interface {
+	DescribeFileContext(ctx context.Context, filePath string) (Metadata, error)
+	GetLatestCursorContext(ctx context.Context, filePath string) (string, error)
+	ListFolderContext(ctx context.Context, folderPath, cursor string) (*Folder, error)
+	ListFolderAllContext(ctx context.Context, folderPath, cursor string) (*Folder, error)
+	ListFolderLongpollContext(ctx context.Context, cursor string, timeout time.Duration) (*LongpollResult, error)
+	DownloadContext(ctx context.Context, filePath string) (*DownloadResult, error)
+	ExportContext(ctx context.Context, filePath, format string) (*ExportResult, error)
+	UploadWithOptionsContext(ctx context.Context, filePath string, r io.Reader, opts UploadOptions) (*FileMetadata, error)
+
+	SearchContext(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error)
+	SearchContinueContext(ctx context.Context, cursor string) (*SearchResult, error)
+	ListRevisionsContext(ctx context.Context, path string, limit int) (*Revisions, error)
+	RestoreContext(ctx context.Context, path, rev string) (*FileMetadata, error)
+
+	CreateSharedLinkContext(ctx context.Context, path string, settings *SharedLinkSettings) (*SharedLink, error)
+	ListSharedLinksContext(ctx context.Context, path string, directOnly bool) ([]SharedLink, error)
+	LockFileBatchContext(ctx context.Context, paths []string) ([]FileLockResult, error)
+	UnlockFileBatchContext(ctx context.Context, paths []string) ([]FileLockResult, error)
+
+	GetCurrentAccountContext(ctx context.Context) (*FullAccount, error)
+	GetSpaceUsageContext(ctx context.Context) (*SpaceUsage, error)
+
+	ResolvePath(p string) string
+	MatchesPath(m Metadata, p string) bool
+}
+
+var _ Files = (*Client)(nil)
+
+// ObservedFiles calls Observe after every operation on Files, with the
+// operation's name, its path (or cursor, or query) and how it went.
+type ObservedFiles struct {
+	Files
+	Observe func(op, arg string, elapsed time.Duration, err error)
+}
+
+// NewLoggingFiles logs every operation on files at debug level, or at error
+// level if it failed for any reason other than its path not existing.
+func NewLoggingFiles(files Files, logger *slog.Logger) *ObservedFiles {
+	return &ObservedFiles{
+		Files: files,
+		Observe: func(op, arg string, elapsed time.Duration, err error) {
+			switch {
+			case err == nil:
+				logger.Debug(fmt.Sprintf("files.%s %q took %s", op, arg, elapsed))
+			case IsNotFound(err):
+				logger.Debug(fmt.Sprintf("files.%s %q not found after %s", op, arg, elapsed))
+			default:
+				logger.Error(fmt.Sprintf("files.%s %q failed after %s: %v", op, arg, elapsed, err))
+			}
+		},
+	}
+}
+
+// NewMetricsFiles records the latency and outcome of every operation on files
+// in metrics.
+func NewMetricsFiles(files Files, metrics *Metrics) *ObservedFiles {
+	return &ObservedFiles{
+		Files: files,
+		Observe: func(op, _ string, elapsed time.Duration, err error) {
+			metrics.Observe(op, elapsed, err)
+		},
+	}
+}
+
+func (f *ObservedFiles) observe(op, arg string, start time.Time, err error) {
+	f.Observe(op, arg, time.Since(start), err)
+}
+
+func (f *ObservedFiles) DescribeFileContext(ctx context.Context, filePath string) (Metadata, error) {
+	start := time.Now()
+	v, err := f.Files.DescribeFileContext(ctx, filePath)
+	f.observe("DescribeFile", filePath, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) GetLatestCursorContext(ctx context.Context, filePath string) (string, error) {
+	start := time.Now()
+	v, err := f.Files.GetLatestCursorContext(ctx, filePath)
+	f.observe("GetLatestCursor", filePath, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) ListFolderContext(ctx context.Context, folderPath, cursor string) (*Folder, error) {
+	start := time.Now()
+	v, err := f.Files.ListFolderContext(ctx, folderPath, cursor)
+	f.observe("ListFolder", folderPath, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) ListFolderAllContext(ctx context.Context, folderPath, cursor string) (*Folder, error) {
+	start := time.Now()
+	v, err := f.Files.ListFolderAllContext(ctx, folderPath, cursor)
+	f.observe("ListFolderAll", folderPath, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) ListFolderLongpollContext(ctx context.Context, cursor string, timeout time.Duration) (*LongpollResult, error) {
+	start := time.Now()
+	v, err := f.Files.ListFolderLongpollContext(ctx, cursor, timeout)
+	f.observe("ListFolderLongpoll", cursor, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) DownloadContext(ctx context.Context, filePath string) (*DownloadResult, error) {
+	start := time.Now()
+	v, err := f.Files.DownloadContext(ctx, filePath)
+	f.observe("Download", filePath, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) ExportContext(ctx context.Context, filePath, format string) (*ExportResult, error) {
+	start := time.Now()
+	v, err := f.Files.ExportContext(ctx, filePath, format)
+	f.observe("Export", filePath, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) UploadWithOptionsContext(ctx context.Context, filePath string, r io.Reader, opts UploadOptions) (*FileMetadata, error) {
+	start := time.Now()
+	v, err := f.Files.UploadWithOptionsContext(ctx, filePath, r, opts)
+	f.observe("Upload", filePath, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) SearchContext(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error) {
+	start := time.Now()
+	v, err := f.Files.SearchContext(ctx, query, opts)
+	f.observe("Search", query, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) SearchContinueContext(ctx context.Context, cursor string) (*SearchResult, error) {
+	start := time.Now()
+	v, err := f.Files.SearchContinueContext(ctx, cursor)
+	f.observe("SearchContinue", cursor, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) ListRevisionsContext(ctx context.Context, path string, limit int) (*Revisions, error) {
+	start := time.Now()
+	v, err := f.Files.ListRevisionsContext(ctx, path, limit)
+	f.observe("ListRevisions", path, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) RestoreContext(ctx context.Context, path, rev string) (*FileMetadata, error) {
+	start := time.Now()
+	v, err := f.Files.RestoreContext(ctx, path, rev)
+	f.observe("Restore", path, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) CreateSharedLinkContext(ctx context.Context, path string, settings *SharedLinkSettings) (*SharedLink, error) {
+	start := time.Now()
+	v, err := f.Files.CreateSharedLinkContext(ctx, path, settings)
+	f.observe("CreateSharedLink", path, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) ListSharedLinksContext(ctx context.Context, path string, directOnly bool) ([]SharedLink, error) {
+	start := time.Now()
+	v, err := f.Files.ListSharedLinksContext(ctx, path, directOnly)
+	f.observe("ListSharedLinks", path, start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) LockFileBatchContext(ctx context.Context, paths []string) ([]FileLockResult, error) {
+	start := time.Now()
+	v, err := f.Files.LockFileBatchContext(ctx, paths)
+	f.observe("LockFileBatch", strings.Join(paths, ","), start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) UnlockFileBatchContext(ctx context.Context, paths []string) ([]FileLockResult, error) {
+	start := time.Now()
+	v, err := f.Files.UnlockFileBatchContext(ctx, paths)
+	f.observe("UnlockFileBatch", strings.Join(paths, ","), start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) GetCurrentAccountContext(ctx context.Context) (*FullAccount, error) {
+	start := time.Now()
+	v, err := f.Files.GetCurrentAccountContext(ctx)
+	f.observe("GetCurrentAccount", "", start, err)
+	return v, err
+}
+
+func (f *ObservedFiles) GetSpaceUsageContext(ctx context.Context) (*SpaceUsage, error) {
+	start := time.Now()
+	v, err := f.Files.GetSpaceUsageContext(ctx)
+	f.observe("GetSpaceUsage", "", start, err)
+	return v, err
+}
@@ -0,0 +1,105 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of Metrics' latency histograms.
+var DefaultLatencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// Metrics counts calls and errors, and keeps a latency histogram, per
+// operation. It's an expvar.Var, so it can be published with expvar.Publish.
+type Metrics struct {
+	// Buckets are the histograms' upper bounds, in increasing order; defaults
+	// to DefaultLatencyBuckets
+	Buckets []time.Duration
+
+	mu  sync.Mutex
+	ops map[string]*OperationStats
+}
+
+type OperationStats struct {
+	Calls  int64 `json:"calls"`
+	Errors int64 `json:"errors"`
+	// Latency counts calls by the first bucket they took no longer than;
+	// the last count is for calls slower than every bucket
+	Latency []int64       `json:"latency"`
+	Total   time.Duration `json:"total_ns"`
+}
+
+func (m *Metrics) buckets() []time.Duration {
+	if m.Buckets == nil {
+		return DefaultLatencyBuckets
+	}
+
+	return m.Buckets
+}
+
+func (m *Metrics) Observe(op string, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buckets := m.buckets()
+	if m.ops == nil {
+		m.ops = make(map[string]*OperationStats)
+	}
+	stats, ok := m.ops[op]
+	if !ok {
+		stats = &OperationStats{Latency: make([]int64, len(buckets)+1)}
+		m.ops[op] = stats
+	}
+
+	stats.Calls++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.Total += elapsed
+
+	i := 0
+	for i < len(buckets) && elapsed > buckets[i] {
+		i++
+	}
+	stats.Latency[i]++
+}
+
+// Snapshot copies the stats of every operation observed so far.
+func (m *Metrics) Snapshot() map[string]OperationStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]OperationStats, len(m.ops))
+	for op, stats := range m.ops {
+		s := *stats
+		s.Latency = append([]int64(nil), stats.Latency...)
+		snapshot[op] = s
+	}
+
+	return snapshot
+}
+
+// String encodes a snapshot as JSON, along with the buckets in milliseconds.
+func (m *Metrics) String() string {
+	var buckets []int64
+	for _, b := range m.buckets() {
+		buckets = append(buckets, b.Milliseconds())
+	}
+
+	b, _ := json.Marshal(map[string]any{
+		"buckets_ms": buckets,
+		"operations": m.Snapshot(),
+	})
+
+	return string(b)
+}
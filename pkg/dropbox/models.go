@@ -1,11 +1,19 @@
 package dropbox
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"time"
 )
 
+// Error is the body of Dropbox's endpoint specific (409) and rate limit (429)
+// error responses.
 type Error struct {
-	Summary string `json:"error_summary"`
+	Summary     string         `json:"error_summary"`
+	UserMessage *LocalizedText `json:"user_message,omitempty"`
+	// Detail is the endpoint specific error union
+	Detail json.RawMessage `json:"error"`
 }
 
 type Cursor struct {
@@ -22,23 +30,43 @@ type (
 	Account string
 )
 
+const (
+	TagFile    = "file"
+	TagFolder  = "folder"
+	TagDeleted = "deleted"
+)
+
 type (
 	Folder struct {
-		Cursor  string `json:"cursor"`
-		Entries []File `json:"entries"`
+		Cursor  string  `json:"cursor"`
+		Entries Entries `json:"entries"`
+		HasMore bool    `json:"has_more"`
+	}
+
+	// Metadata is one of *FileMetadata, *FolderMetadata or *DeletedMetadata,
+	// depending on the entry's .tag.
+	Metadata interface {
+		Base() *BaseMetadata
 	}
 
-	File struct {
-		Tag                      string          `json:".tag"`
+	Entries []Metadata
+
+	BaseMetadata struct {
+		Tag         string `json:".tag"`
+		Name        string `json:"name"`
+		PathDisplay string `json:"path_display"`
+		PathLower   string `json:"path_lower"`
+	}
+
+	FileMetadata struct {
+		BaseMetadata
 		ClientModified           time.Time       `json:"client_modified"`
 		ContentHash              string          `json:"content_hash"`
-		FileLockInfo             FileLockInfo    `json:"file_lock_info"`
+		ExportInfo               *ExportInfo     `json:"export_info,omitempty"`
+		FileLockInfo             *FileLockInfo   `json:"file_lock_info,omitempty"`
 		HasExplicitSharedMembers bool            `json:"has_explicit_shared_members"`
 		ID                       string          `json:"id"`
 		IsDownloadable           bool            `json:"is_downloadable"`
-		Name                     string          `json:"name"`
-		PathDisplay              string          `json:"path_display"`
-		PathLower                string          `json:"path_lower"`
 		PropertyGroups           []PropertyGroup `json:"property_groups"`
 		Rev                      string          `json:"rev"`
 		ServerModified           time.Time       `json:"server_modified"`
@@ -46,6 +74,29 @@ type (
 		Size                     int             `json:"size"`
 	}
 
+	FolderMetadata struct {
+		BaseMetadata
+		ID             string          `json:"id"`
+		PropertyGroups []PropertyGroup `json:"property_groups"`
+	}
+
+	DeletedMetadata struct {
+		BaseMetadata
+	}
+
+	// File is the old name of FileMetadata.
+	//
+	// Deprecated: use FileMetadata.
+	File = FileMetadata
+
+	// ExportInfo is set for files that can't be downloaded, like Google
+	// Sheets, only exported; see Client.Export.
+	ExportInfo struct {
+		// ExportAs is the default export format
+		ExportAs      string   `json:"export_as"`
+		ExportOptions []string `json:"export_options"`
+	}
+
 	SharingInfo struct {
 		ModifiedBy           string `json:"modified_by"`
 		ParentSharedFolderID string `json:"parent_shared_folder_id"`
@@ -53,10 +104,152 @@ type (
 	}
 
 	FileLockInfo struct {
-		// TODO
+		IsLockholder        bool      `json:"is_lockholder"`
+		LockholderName      string    `json:"lockholder_name"`
+		LockholderAccountID string    `json:"lockholder_account_id"`
+		Created             time.Time `json:"created"`
 	}
 
+	// PropertyGroup holds a file's values for the fields of a property
+	// template.
 	PropertyGroup struct {
-		// TODO
+		TemplateID string          `json:"template_id"`
+		Fields     []PropertyField `json:"fields"`
+	}
+
+	PropertyField struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+)
+
+// Field returns the value of the named field, if it's set.
+func (g PropertyGroup) Field(name string) (string, bool) {
+	for _, f := range g.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+
+	return "", false
+}
+
+func (m *BaseMetadata) Base() *BaseMetadata {
+	return m
+}
+
+// UnmarshalMetadata decodes a file, folder or deleted entry according to its
+// .tag.
+func UnmarshalMetadata(data []byte) (Metadata, error) {
+	var base BaseMetadata
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, err
+	}
+
+	var m Metadata
+	switch base.Tag {
+	case TagFile:
+		m = &FileMetadata{}
+	case TagFolder:
+		m = &FolderMetadata{}
+	case TagDeleted:
+		m = &DeletedMetadata{}
+	default:
+		return nil, fmt.Errorf("unknown metadata tag %q", base.Tag)
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
 	}
+
+	return m, nil
+}
+
+func (e *Entries) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	entries := make(Entries, len(raw))
+	for i := range raw {
+		m, err := UnmarshalMetadata(raw[i])
+		if err != nil {
+			return fmt.Errorf("error decoding entry %d: %w", i, err)
+		}
+		entries[i] = m
+	}
+
+	*e = entries
+	return nil
+}
+
+// Timestamp is a time sent to Dropbox, which only accepts UTC times to the
+// second, like "2006-01-02T15:04:05Z". It's parsed like any RFC 3339 time.
+type Timestamp struct {
+	time.Time
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format("2006-01-02T15:04:05Z"))
+}
+
+// WriteMode selects what Dropbox does when an upload's path already exists.
+// The zero value overwrites.
+type WriteMode struct {
+	Tag string
+	Rev string
+}
+
+var (
+	// WriteModeAdd never overwrites; with UploadOptions.Autorename a
+	// conflicting upload is renamed instead of rejected
+	WriteModeAdd = WriteMode{Tag: "add"}
+	// WriteModeOverwrite always overwrites
+	WriteModeOverwrite = WriteMode{Tag: "overwrite"}
 )
+
+// WriteModeUpdate only overwrites the file if it's still at rev.
+func WriteModeUpdate(rev string) WriteMode {
+	return WriteMode{Tag: "update", Rev: rev}
+}
+
+func (m WriteMode) MarshalJSON() ([]byte, error) {
+	switch m.Tag {
+	case "update":
+		return json.Marshal(map[string]string{
+			".tag":   m.Tag,
+			"update": m.Rev,
+		})
+	case "":
+		return json.Marshal(WriteModeOverwrite.Tag)
+	default:
+		return json.Marshal(m.Tag)
+	}
+}
+
+type UploadOptions struct {
+	Mode       WriteMode
+	Autorename bool
+}
+
+func (o UploadOptions) commitInfo(filePath string) map[string]any {
+	return map[string]any{
+		"path":       filePath,
+		"mode":       o.Mode,
+		"autorename": o.Autorename,
+	}
+}
+
+// DownloadResult is a downloaded file's metadata, as of the revision that was
+// downloaded, and its content. The caller must close Body.
+type DownloadResult struct {
+	File FileMetadata
+	Body io.ReadCloser
+}
+
+type LongpollResult struct {
+	Changes bool `json:"changes"`
+	// Backoff is how many seconds to wait before longpolling again
+	Backoff int `json:"backoff"`
+}
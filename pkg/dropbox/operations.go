@@ -0,0 +1,279 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+var (
+	// how long to wait between checks of an async batch job, doubling up to
+	// MaxBatchPollInterval
+	BatchPollInterval    = 500 * time.Millisecond
+	MaxBatchPollInterval = 10 * time.Second
+)
+
+type (
+	RelocationOptions struct {
+		Autorename             bool
+		AllowOwnershipTransfer bool
+	}
+
+	RelocationPath struct {
+		FromPath string `json:"from_path"`
+		ToPath   string `json:"to_path"`
+	}
+
+	// BatchEntryResult is the outcome of one entry of a batch operation:
+	// either Metadata or Failure is set.
+	BatchEntryResult struct {
+		Metadata Metadata
+		Failure  *BatchEntryError
+	}
+
+	metadataResult struct {
+		Metadata json.RawMessage `json:"metadata"`
+	}
+)
+
+func (c *Client) Move(fromPath, toPath string, opts RelocationOptions) (Metadata, error) {
+	return c.MoveContext(context.Background(), fromPath, toPath, opts)
+}
+
+func (c *Client) MoveContext(ctx context.Context, fromPath, toPath string, opts RelocationOptions) (Metadata, error) {
+	return c.relocate(ctx, "/files/move_v2", fromPath, toPath, opts)
+}
+
+func (c *Client) Copy(fromPath, toPath string, opts RelocationOptions) (Metadata, error) {
+	return c.CopyContext(context.Background(), fromPath, toPath, opts)
+}
+
+func (c *Client) CopyContext(ctx context.Context, fromPath, toPath string, opts RelocationOptions) (Metadata, error) {
+	return c.relocate(ctx, "/files/copy_v2", fromPath, toPath, opts)
+}
+
+func (c *Client) relocate(ctx context.Context, urlPath, fromPath, toPath string, opts RelocationOptions) (Metadata, error) {
+	params := map[string]any{
+		"from_path":                c.ResolvePath(fromPath),
+		"to_path":                  c.ResolvePath(toPath),
+		"autorename":               opts.Autorename,
+		"allow_ownership_transfer": opts.AllowOwnershipTransfer,
+	}
+
+	var result metadataResult
+	if err := c.rpc(ctx, urlPath, params, &result); err != nil {
+		return nil, err
+	}
+
+	return UnmarshalMetadata(result.Metadata)
+}
+
+func (c *Client) Delete(path string) (Metadata, error) {
+	return c.DeleteContext(context.Background(), path)
+}
+
+func (c *Client) DeleteContext(ctx context.Context, path string) (Metadata, error) {
+	params := map[string]any{
+		"path": c.ResolvePath(path),
+	}
+
+	var result metadataResult
+	if err := c.rpc(ctx, "/files/delete_v2", params, &result); err != nil {
+		return nil, err
+	}
+
+	return UnmarshalMetadata(result.Metadata)
+}
+
+func (c *Client) CreateFolder(path string, autorename bool) (*FolderMetadata, error) {
+	return c.CreateFolderContext(context.Background(), path, autorename)
+}
+
+func (c *Client) CreateFolderContext(ctx context.Context, path string, autorename bool) (*FolderMetadata, error) {
+	params := map[string]any{
+		"path":       c.ResolvePath(path),
+		"autorename": autorename,
+	}
+
+	var result struct {
+		Metadata FolderMetadata `json:"metadata"`
+	}
+	if err := c.rpc(ctx, "/files/create_folder_v2", params, &result); err != nil {
+		return nil, err
+	}
+
+	result.Metadata.Tag = TagFolder
+	return &result.Metadata, nil
+}
+
+// MoveBatch moves each entry, waiting for the async job to complete. Results
+// are in the same order as entries.
+func (c *Client) MoveBatch(entries []RelocationPath, opts RelocationOptions) ([]BatchEntryResult, error) {
+	return c.MoveBatchContext(context.Background(), entries, opts)
+}
+
+func (c *Client) MoveBatchContext(ctx context.Context, entries []RelocationPath, opts RelocationOptions) ([]BatchEntryResult, error) {
+	params := map[string]any{
+		"entries":                  c.resolveRelocations(entries),
+		"autorename":               opts.Autorename,
+		"allow_ownership_transfer": opts.AllowOwnershipTransfer,
+	}
+
+	return c.batch(ctx, "/files/move_batch_v2", "/files/move_batch/check_v2", params, "success")
+}
+
+// CopyBatch copies each entry, waiting for the async job to complete. Results
+// are in the same order as entries.
+func (c *Client) CopyBatch(entries []RelocationPath, opts RelocationOptions) ([]BatchEntryResult, error) {
+	return c.CopyBatchContext(context.Background(), entries, opts)
+}
+
+func (c *Client) CopyBatchContext(ctx context.Context, entries []RelocationPath, opts RelocationOptions) ([]BatchEntryResult, error) {
+	params := map[string]any{
+		"entries":    c.resolveRelocations(entries),
+		"autorename": opts.Autorename,
+	}
+
+	return c.batch(ctx, "/files/copy_batch_v2", "/files/copy_batch/check_v2", params, "success")
+}
+
+// DeleteBatch deletes each path, waiting for the async job to complete.
+// Results are in the same order as paths.
+func (c *Client) DeleteBatch(paths []string) ([]BatchEntryResult, error) {
+	return c.DeleteBatchContext(context.Background(), paths)
+}
+
+func (c *Client) DeleteBatchContext(ctx context.Context, paths []string) ([]BatchEntryResult, error) {
+	entries := make([]map[string]string, len(paths))
+	for i := range paths {
+		entries[i] = map[string]string{"path": c.ResolvePath(paths[i])}
+	}
+	params := map[string]any{
+		"entries": entries,
+	}
+
+	return c.batch(ctx, "/files/delete_batch", "/files/delete_batch/check", params, "metadata")
+}
+
+// CreateFolderBatch creates each folder, waiting for the async job to
+// complete. Results are in the same order as paths.
+func (c *Client) CreateFolderBatch(paths []string, autorename bool) ([]BatchEntryResult, error) {
+	return c.CreateFolderBatchContext(context.Background(), paths, autorename)
+}
+
+func (c *Client) CreateFolderBatchContext(ctx context.Context, paths []string, autorename bool) ([]BatchEntryResult, error) {
+	resolved := make([]string, len(paths))
+	for i := range paths {
+		resolved[i] = c.ResolvePath(paths[i])
+	}
+	params := map[string]any{
+		"paths":      resolved,
+		"autorename": autorename,
+	}
+
+	return c.batch(ctx, "/files/create_folder_batch", "/files/create_folder_batch/check", params, "metadata")
+}
+
+func (c *Client) resolveRelocations(entries []RelocationPath) []RelocationPath {
+	resolved := make([]RelocationPath, len(entries))
+	for i, e := range entries {
+		resolved[i] = RelocationPath{
+			FromPath: c.ResolvePath(e.FromPath),
+			ToPath:   c.ResolvePath(e.ToPath),
+		}
+	}
+
+	return resolved
+}
+
+// batchJobStatus covers both the launch and check responses of batch
+// operations: either the job's complete, or it's identified by an ID to check
+// back on.
+type batchJobStatus struct {
+	Tag        string            `json:".tag"`
+	AsyncJobID string            `json:"async_job_id"`
+	Entries    []json.RawMessage `json:"entries"`
+}
+
+// batch launches a batch operation and polls its job until it's done. Each
+// successful entry's metadata is found under metadataKey.
+func (c *Client) batch(ctx context.Context, launchPath, checkPath string, params any, metadataKey string) ([]BatchEntryResult, error) {
+	var status batchJobStatus
+	if err := c.rpc(ctx, launchPath, params, &status); err != nil {
+		return nil, err
+	}
+
+	interval := BatchPollInterval
+	for status.Tag == "async_job_id" || status.Tag == "in_progress" {
+		if status.AsyncJobID != "" {
+			params = map[string]any{
+				"async_job_id": status.AsyncJobID,
+			}
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+		interval = min(2*interval, MaxBatchPollInterval)
+
+		c.Logger.Debug(fmt.Sprintf("client.batch: checking %s", launchPath))
+		status = batchJobStatus{}
+		if err := c.rpc(ctx, checkPath, params, &status); err != nil {
+			return nil, err
+		}
+	}
+
+	if status.Tag != "complete" {
+		return nil, fmt.Errorf("%s job %s", launchPath, status.Tag)
+	}
+
+	results := make([]BatchEntryResult, len(status.Entries))
+	for i, raw := range status.Entries {
+		var entry map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, fmt.Errorf("error parsing %s entry %d: %w", launchPath, i, err)
+		}
+
+		var tag string
+		if err := json.Unmarshal(entry[".tag"], &tag); err != nil {
+			return nil, fmt.Errorf("error parsing %s entry %d: %w", launchPath, i, err)
+		}
+
+		switch tag {
+		case "success":
+			metadata, err := unmarshalResultMetadata(entry[metadataKey])
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s entry %d: %w", launchPath, i, err)
+			}
+			results[i].Metadata = metadata
+		default:
+			results[i].Failure = &BatchEntryError{
+				Tags:   unionTags(entry["failure"]),
+				Detail: entry["failure"],
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// unmarshalResultMetadata is UnmarshalMetadata for results that may hold
+// untagged folder metadata, e.g. create_folder_batch.
+func unmarshalResultMetadata(raw json.RawMessage) (Metadata, error) {
+	var base BaseMetadata
+	if err := json.Unmarshal(raw, &base); err != nil {
+		return nil, err
+	}
+	if base.Tag != "" {
+		return UnmarshalMetadata(raw)
+	}
+
+	var folder FolderMetadata
+	if err := json.Unmarshal(raw, &folder); err != nil {
+		return nil, err
+	}
+	folder.Tag = TagFolder
+
+	return &folder, nil
+}
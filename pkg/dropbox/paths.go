@@ -0,0 +1,71 @@
+package dropbox
+
+import (
+	"path"
+	"strings"
+)
+
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultBaseURL
+	}
+
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+func (c *Client) notifyURL() string {
+	if c.NotifyURL == "" {
+		return DefaultNotifyURL
+	}
+
+	return strings.TrimSuffix(c.NotifyURL, "/")
+}
+
+func (c *Client) contentURL() string {
+	if c.ContentURL == "" {
+		return DefaultContentURL
+	}
+
+	return strings.TrimSuffix(c.ContentURL, "/")
+}
+
+func (c *Client) rootFolder() string {
+	if c.RootFolder == "" {
+		return DefaultRootFolder
+	}
+
+	return c.RootFolder
+}
+
+// ResolvePath turns p into the path Dropbox expects:
+//   - IDs ("id:..."), revisions ("rev:...") and namespace-relative paths
+//     ("ns:...") are left as they are
+//   - relative paths are joined onto RootFolder
+//   - "." and ".." elements, repeated slashes and trailing slashes are
+//     removed
+//   - the root of the account is "", as list_folder expects
+func (c *Client) ResolvePath(p string) string {
+	for _, prefix := range []string{"id:", "rev:", "ns:"} {
+		if strings.HasPrefix(p, prefix) {
+			return p
+		}
+	}
+
+	if !strings.HasPrefix(p, "/") {
+		p = c.rootFolder() + "/" + p
+	}
+
+	p = path.Clean(p)
+	if p == "/" {
+		return ""
+	}
+
+	return p
+}
+
+// MatchesPath reports whether m is the entry at p, which is resolved with
+// ResolvePath. Dropbox paths are case-insensitive, so p is matched against
+// the entry's PathLower.
+func (c *Client) MatchesPath(m Metadata, p string) bool {
+	return strings.EqualFold(c.ResolvePath(p), m.Base().PathLower)
+}
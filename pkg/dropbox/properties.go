@@ -0,0 +1,194 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type (
+	// PropertyTemplate describes a set of fields that can be attached to
+	// files as a PropertyGroup.
+	PropertyTemplate struct {
+		Name        string                  `json:"name"`
+		Description string                  `json:"description"`
+		Fields      []PropertyFieldTemplate `json:"fields"`
+	}
+
+	PropertyFieldTemplate struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	PropertyTemplateUpdate struct {
+		Name        string
+		Description string
+		AddFields   []PropertyFieldTemplate
+	}
+
+	PropertyGroupUpdate struct {
+		TemplateID        string          `json:"template_id"`
+		AddOrUpdateFields []PropertyField `json:"add_or_update_fields,omitempty"`
+		RemoveFields      []string        `json:"remove_fields,omitempty"`
+	}
+)
+
+func (c *Client) includePropertyGroups(params map[string]any) {
+	if len(c.PropertyTemplateIDs) == 0 {
+		return
+	}
+
+	params["include_property_groups"] = map[string]any{
+		".tag":        "filter_some",
+		"filter_some": c.PropertyTemplateIDs,
+	}
+}
+
+// MarshalJSON adds the field's type, which is always a string.
+func (f PropertyFieldTemplate) MarshalJSON() ([]byte, error) {
+	type field PropertyFieldTemplate
+	return json.Marshal(struct {
+		field
+		Type string `json:"type"`
+	}{field(f), "string"})
+}
+
+// AddPropertyTemplate adds a template to the user's account, returning its ID.
+func (c *Client) AddPropertyTemplate(template PropertyTemplate) (string, error) {
+	return c.AddPropertyTemplateContext(context.Background(), template)
+}
+
+func (c *Client) AddPropertyTemplateContext(ctx context.Context, template PropertyTemplate) (string, error) {
+	var result struct {
+		TemplateID string `json:"template_id"`
+	}
+	if err := c.rpc(ctx, "/file_properties/templates/add_for_user", template, &result); err != nil {
+		return "", err
+	}
+
+	return result.TemplateID, nil
+}
+
+func (c *Client) GetPropertyTemplate(templateID string) (*PropertyTemplate, error) {
+	return c.GetPropertyTemplateContext(context.Background(), templateID)
+}
+
+func (c *Client) GetPropertyTemplateContext(ctx context.Context, templateID string) (*PropertyTemplate, error) {
+	params := map[string]any{
+		"template_id": templateID,
+	}
+
+	var template PropertyTemplate
+	if err := c.rpc(ctx, "/file_properties/templates/get_for_user", params, &template); err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// ListPropertyTemplates returns the IDs of the user's templates.
+func (c *Client) ListPropertyTemplates() ([]string, error) {
+	return c.ListPropertyTemplatesContext(context.Background())
+}
+
+func (c *Client) ListPropertyTemplatesContext(ctx context.Context) ([]string, error) {
+	var result struct {
+		TemplateIDs []string `json:"template_ids"`
+	}
+	if err := c.rpc(ctx, "/file_properties/templates/list_for_user", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result.TemplateIDs, nil
+}
+
+func (c *Client) UpdatePropertyTemplate(templateID string, update PropertyTemplateUpdate) error {
+	return c.UpdatePropertyTemplateContext(context.Background(), templateID, update)
+}
+
+func (c *Client) UpdatePropertyTemplateContext(ctx context.Context, templateID string, update PropertyTemplateUpdate) error {
+	params := map[string]any{
+		"template_id": templateID,
+	}
+	if update.Name != "" {
+		params["name"] = update.Name
+	}
+	if update.Description != "" {
+		params["description"] = update.Description
+	}
+	if len(update.AddFields) != 0 {
+		params["add_fields"] = update.AddFields
+	}
+
+	return c.rpc(ctx, "/file_properties/templates/update_for_user", params, nil)
+}
+
+func (c *Client) RemovePropertyTemplate(templateID string) error {
+	return c.RemovePropertyTemplateContext(context.Background(), templateID)
+}
+
+func (c *Client) RemovePropertyTemplateContext(ctx context.Context, templateID string) error {
+	params := map[string]any{
+		"template_id": templateID,
+	}
+
+	return c.rpc(ctx, "/file_properties/templates/remove_for_user", params, nil)
+}
+
+// AddProperties attaches property groups to a file. It fails if the file
+// already has a group for any of the templates.
+func (c *Client) AddProperties(path string, groups []PropertyGroup) error {
+	return c.AddPropertiesContext(context.Background(), path, groups)
+}
+
+func (c *Client) AddPropertiesContext(ctx context.Context, path string, groups []PropertyGroup) error {
+	params := map[string]any{
+		"path":            c.ResolvePath(path),
+		"property_groups": groups,
+	}
+
+	return c.rpc(ctx, "/file_properties/properties/add", params, nil)
+}
+
+// OverwriteProperties replaces the file's property groups for the groups'
+// templates.
+func (c *Client) OverwriteProperties(path string, groups []PropertyGroup) error {
+	return c.OverwritePropertiesContext(context.Background(), path, groups)
+}
+
+func (c *Client) OverwritePropertiesContext(ctx context.Context, path string, groups []PropertyGroup) error {
+	params := map[string]any{
+		"path":            c.ResolvePath(path),
+		"property_groups": groups,
+	}
+
+	return c.rpc(ctx, "/file_properties/properties/overwrite", params, nil)
+}
+
+// UpdateProperties adds, updates and removes individual fields of the file's
+// property groups.
+func (c *Client) UpdateProperties(path string, updates []PropertyGroupUpdate) error {
+	return c.UpdatePropertiesContext(context.Background(), path, updates)
+}
+
+func (c *Client) UpdatePropertiesContext(ctx context.Context, path string, updates []PropertyGroupUpdate) error {
+	params := map[string]any{
+		"path":                   c.ResolvePath(path),
+		"update_property_groups": updates,
+	}
+
+	return c.rpc(ctx, "/file_properties/properties/update", params, nil)
+}
+
+// RemoveProperties removes the file's property groups for the templates.
+func (c *Client) RemoveProperties(path string, templateIDs []string) error {
+	return c.RemovePropertiesContext(context.Background(), path, templateIDs)
+}
+
+func (c *Client) RemovePropertiesContext(ctx context.Context, path string, templateIDs []string) error {
+	params := map[string]any{
+		"path":                  c.ResolvePath(path),
+		"property_template_ids": templateIDs,
+	}
+
+	return c.rpc(ctx, "/file_properties/properties/remove", params, nil)
+}
@@ -0,0 +1,129 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// RetryPolicy controls how the client retries rate limited (429) and failed
+// (5xx, transport error) requests. Rate limited requests are always retried,
+// as long as their body can be replayed, since Dropbox rejects them before
+// doing any work; everything else is only retried against endpoints listed in
+// idempotentEndpoints.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values less than 2 disable retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// endpoints that can be safely replayed after a server or transport error
+var idempotentEndpoints = map[string]bool{
+	"/files/get_metadata":                  true,
+	"/files/list_folder":                   true,
+	"/files/list_folder/continue":          true,
+	"/files/list_folder/get_latest_cursor": true,
+	"/files/download":                      true,
+	"/files/download_zip":                  true,
+	"/files/export":                        true,
+	"/users/get_current_account":           true,
+	"/users/get_space_usage":               true,
+	// replaying an upload either rewrites the same content or conflicts, unless
+	// it autorenames; see Client.UploadWithOptions
+	"/files/upload": true,
+	// appends are at a fixed offset, so replaying one that already landed
+	// fails with incorrect_offset rather than appending twice
+	"/files/upload_session/append_v2": true,
+}
+
+func (c *Client) retryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy
+	if c.Retry != nil {
+		policy = *c.Retry
+	}
+
+	// negative delays would make backoff panic
+	policy.BaseDelay = max(policy.BaseDelay, 0)
+	policy.MaxDelay = max(policy.MaxDelay, 0)
+
+	return policy
+}
+
+// shouldRetry reports whether the outcome of an attempt is retryable, and the
+// delay the server asked for, if any.
+//
+// Requests with streamed bodies, which have no GetBody, are never retried,
+// not even when a 429 or 503 says the server rejected them before reading
+// the body: the transport may already have consumed part of it, and there's
+// no way to rewind it.
+func shouldRetry(req *http.Request, idempotent bool, resp *http.Response, err error) (bool, time.Duration) {
+	if req.Body != nil && req.GetBody == nil {
+		return false, 0
+	}
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false, 0
+		}
+
+		return idempotent, 0
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true, retryAfter(resp)
+	case resp.StatusCode >= 500:
+		return idempotent, retryAfter(resp)
+	default:
+		return false, 0
+	}
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// backoff returns the delay before the given retry (starting at 1) using
+// exponential backoff with full jitter. A server provided delay takes
+// precedence, with a little jitter so concurrent callers don't stampede.
+func (p RetryPolicy) backoff(retry int, serverDelay time.Duration) time.Duration {
+	if serverDelay > 0 {
+		return serverDelay + rand.N(p.BaseDelay+1)
+	}
+
+	ceiling := p.BaseDelay << (retry - 1)
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+
+	return rand.N(ceiling + 1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("error waiting to retry: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
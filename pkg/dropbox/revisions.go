@@ -0,0 +1,57 @@
+package dropbox
+
+import (
+	"context"
+	"time"
+)
+
+type Revisions struct {
+	IsDeleted     bool           `json:"is_deleted"`
+	ServerDeleted *time.Time     `json:"server_deleted,omitempty"`
+	Entries       []FileMetadata `json:"entries"`
+}
+
+// ListRevisions lists up to limit (at most 100) of the file's revisions, most
+// recent first.
+func (c *Client) ListRevisions(path string, limit int) (*Revisions, error) {
+	return c.ListRevisionsContext(context.Background(), path, limit)
+}
+
+func (c *Client) ListRevisionsContext(ctx context.Context, path string, limit int) (*Revisions, error) {
+	params := map[string]any{
+		"path":  c.ResolvePath(path),
+		"mode":  "path",
+		"limit": limit,
+	}
+
+	var revisions Revisions
+	if err := c.rpc(ctx, "/files/list_revisions", params, &revisions); err != nil {
+		return nil, err
+	}
+
+	for i := range revisions.Entries {
+		revisions.Entries[i].Tag = TagFile
+	}
+
+	return &revisions, nil
+}
+
+// Restore makes rev the file's latest revision.
+func (c *Client) Restore(path, rev string) (*FileMetadata, error) {
+	return c.RestoreContext(context.Background(), path, rev)
+}
+
+func (c *Client) RestoreContext(ctx context.Context, path, rev string) (*FileMetadata, error) {
+	params := map[string]any{
+		"path": c.ResolvePath(path),
+		"rev":  rev,
+	}
+
+	var file FileMetadata
+	if err := c.rpc(ctx, "/files/restore", params, &file); err != nil {
+		return nil, err
+	}
+	file.Tag = TagFile
+
+	return &file, nil
+}
@@ -0,0 +1,112 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+type (
+	SearchOptions struct {
+		// Path scopes the search, resolved with Client.ResolvePath; defaults
+		// to the client's RootFolder
+		Path string
+		// MaxResults per page, at most 1000; defaults to 100
+		MaxResults int
+		// FilenameOnly skips matching file contents
+		FilenameOnly bool
+		// Deleted searches deleted files instead of active ones
+		Deleted bool
+	}
+
+	SearchMatch struct {
+		Metadata Metadata `json:"metadata"`
+		// MatchType is one of "filename", "file_content",
+		// "filename_and_content" or "image_content"
+		MatchType string `json:"match_type"`
+	}
+
+	// SearchResult is a page of matches; pass Cursor to SearchContinue for
+	// the next page while HasMore.
+	SearchResult struct {
+		Matches []SearchMatch `json:"matches"`
+		HasMore bool          `json:"has_more"`
+		Cursor  string        `json:"cursor"`
+	}
+
+	searchMatch struct {
+		Metadata struct {
+			Metadata json.RawMessage `json:"metadata"`
+		} `json:"metadata"`
+		MatchType struct {
+			Tag string `json:".tag"`
+		} `json:"match_type"`
+	}
+)
+
+func (m *SearchMatch) UnmarshalJSON(data []byte) error {
+	var match searchMatch
+	if err := json.Unmarshal(data, &match); err != nil {
+		return err
+	}
+
+	metadata, err := UnmarshalMetadata(match.Metadata.Metadata)
+	if err != nil {
+		return err
+	}
+
+	m.Metadata = metadata
+	m.MatchType = match.MatchType.Tag
+	return nil
+}
+
+func (c *Client) Search(query string, opts SearchOptions) (*SearchResult, error) {
+	return c.SearchContext(context.Background(), query, opts)
+}
+
+func (c *Client) SearchContext(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error) {
+	path := c.ResolvePath(opts.Path)
+
+	status := "active"
+	if opts.Deleted {
+		status = "deleted"
+	}
+
+	options := map[string]any{
+		"path":          path,
+		"filename_only": opts.FilenameOnly,
+		"file_status":   status,
+	}
+	if opts.MaxResults > 0 {
+		options["max_results"] = opts.MaxResults
+	}
+
+	params := map[string]any{
+		"query":   query,
+		"options": options,
+	}
+
+	var result SearchResult
+	if err := c.rpc(ctx, "/files/search_v2", params, &result); err != nil {
+		return nil, fmt.Errorf("error searching for %q: %w", query, err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) SearchContinue(cursor string) (*SearchResult, error) {
+	return c.SearchContinueContext(context.Background(), cursor)
+}
+
+func (c *Client) SearchContinueContext(ctx context.Context, cursor string) (*SearchResult, error) {
+	params := map[string]any{
+		"cursor": cursor,
+	}
+
+	var result SearchResult
+	if err := c.rpc(ctx, "/files/search/continue_v2", params, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
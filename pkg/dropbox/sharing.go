@@ -0,0 +1,108 @@
+package dropbox
+
+import (
+	"context"
+)
+
+type (
+	// SharedLinkSettings configures a new shared link; empty fields take the
+	// account's defaults.
+	SharedLinkSettings struct {
+		// Audience is one of "public", "team" or "no_one"
+		Audience string `json:"audience,omitempty"`
+		// Access is one of "viewer" or "editor"
+		Access          string     `json:"access,omitempty"`
+		RequirePassword bool       `json:"require_password,omitempty"`
+		LinkPassword    string     `json:"link_password,omitempty"`
+		Expires         *Timestamp `json:"expires,omitempty"`
+		AllowDownload   *bool      `json:"allow_download,omitempty"`
+	}
+
+	SharedLink struct {
+		Tag       string     `json:".tag"`
+		URL       string     `json:"url"`
+		ID        string     `json:"id"`
+		Name      string     `json:"name"`
+		PathLower string     `json:"path_lower"`
+		Expires   *Timestamp `json:"expires,omitempty"`
+	}
+
+	TemporaryLink struct {
+		Metadata FileMetadata `json:"metadata"`
+		Link     string       `json:"link"`
+	}
+)
+
+// CreateSharedLink shares the path. It fails with ErrSharedLinkExists when
+// the path already has a link; see ListSharedLinks.
+func (c *Client) CreateSharedLink(path string, settings *SharedLinkSettings) (*SharedLink, error) {
+	return c.CreateSharedLinkContext(context.Background(), path, settings)
+}
+
+func (c *Client) CreateSharedLinkContext(ctx context.Context, path string, settings *SharedLinkSettings) (*SharedLink, error) {
+	params := map[string]any{
+		"path": c.ResolvePath(path),
+	}
+	if settings != nil {
+		params["settings"] = settings
+	}
+
+	var link SharedLink
+	if err := c.rpc(ctx, "/sharing/create_shared_link_with_settings", params, &link); err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+// ListSharedLinks lists the links to path, or to its parents too unless
+// directOnly.
+func (c *Client) ListSharedLinks(path string, directOnly bool) ([]SharedLink, error) {
+	return c.ListSharedLinksContext(context.Background(), path, directOnly)
+}
+
+func (c *Client) ListSharedLinksContext(ctx context.Context, path string, directOnly bool) ([]SharedLink, error) {
+	params := map[string]any{
+		"path":        c.ResolvePath(path),
+		"direct_only": directOnly,
+	}
+
+	var links []SharedLink
+	for {
+		var page struct {
+			Links   []SharedLink `json:"links"`
+			HasMore bool         `json:"has_more"`
+			Cursor  string       `json:"cursor"`
+		}
+		if err := c.rpc(ctx, "/sharing/list_shared_links", params, &page); err != nil {
+			return nil, err
+		}
+
+		links = append(links, page.Links...)
+		if !page.HasMore {
+			return links, nil
+		}
+
+		params["cursor"] = page.Cursor
+	}
+}
+
+// GetTemporaryLink returns a link to download the file directly, which
+// expires after four hours.
+func (c *Client) GetTemporaryLink(path string) (*TemporaryLink, error) {
+	return c.GetTemporaryLinkContext(context.Background(), path)
+}
+
+func (c *Client) GetTemporaryLinkContext(ctx context.Context, path string) (*TemporaryLink, error) {
+	params := map[string]any{
+		"path": c.ResolvePath(path),
+	}
+
+	var link TemporaryLink
+	if err := c.rpc(ctx, "/files/get_temporary_link", params, &link); err != nil {
+		return nil, err
+	}
+	link.Metadata.Tag = TagFile
+
+	return &link, nil
+}
@@ -0,0 +1,31 @@
+package dropbox_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+)
+
+func TestSharedLinkSettingsExpires(t *testing.T) {
+	expires := time.Date(2026, 3, 4, 5, 6, 7, 890, time.FixedZone("PST", -8*60*60))
+
+	b, err := json.Marshal(&dropbox.SharedLinkSettings{
+		Expires: &dropbox.Timestamp{Time: expires},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"expires":"2026-03-04T13:06:07Z"}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var link dropbox.SharedLink
+	if err := json.Unmarshal([]byte(`{"expires":"2026-03-04T13:06:07Z"}`), &link); err != nil {
+		t.Fatal(err)
+	}
+	if !link.Expires.Equal(expires.Truncate(time.Second)) {
+		t.Errorf("got %s, want %s", link.Expires, expires)
+	}
+}
@@ -0,0 +1,146 @@
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PathRoot is the namespace paths are relative to, sent as the
+// Dropbox-API-Path-Root header. By default paths are relative to the user's
+// home namespace, which on team accounts doesn't include team folders.
+type PathRoot struct {
+	Tag         string
+	NamespaceID string
+}
+
+// PathRootHome is the user's home namespace, the default.
+func PathRootHome() PathRoot {
+	return PathRoot{Tag: "home"}
+}
+
+// PathRootRoot is the user's root namespace, which for team members is the
+// team space. Requests fail with ErrInvalidRoot if it isn't namespaceID.
+func PathRootRoot(namespaceID string) PathRoot {
+	return PathRoot{Tag: "root", NamespaceID: namespaceID}
+}
+
+// PathRootNamespace is any namespace the user can access, like a team or
+// shared folder.
+func PathRootNamespace(namespaceID string) PathRoot {
+	return PathRoot{Tag: "namespace_id", NamespaceID: namespaceID}
+}
+
+// TeamPathRoot is the account's root namespace: the team space for team
+// members, or their home otherwise.
+func TeamPathRoot(account *FullAccount) PathRoot {
+	return PathRootRoot(account.RootInfo.RootNamespaceID)
+}
+
+func (r PathRoot) MarshalJSON() ([]byte, error) {
+	switch r.Tag {
+	case "root", "namespace_id":
+		return json.Marshal(map[string]string{
+			".tag": r.Tag,
+			r.Tag:  r.NamespaceID,
+		})
+	default:
+		return json.Marshal(map[string]string{".tag": r.Tag})
+	}
+}
+
+// Selector is the team member requests made with a team token act as. Only
+// one member can be selected at a time, as either a user or an admin.
+type Selector struct {
+	// Admin selects the member as a team admin, with
+	// Dropbox-API-Select-Admin, rather than as a user, with
+	// Dropbox-API-Select-User
+	Admin    bool
+	MemberID string
+}
+
+// SelectUser acts on behalf of the team member.
+func SelectUser(memberID string) Selector {
+	return Selector{MemberID: memberID}
+}
+
+// SelectAdmin acts as the team member, who must be a team admin.
+func SelectAdmin(memberID string) Selector {
+	return Selector{Admin: true, MemberID: memberID}
+}
+
+type (
+	pathRootKey struct{}
+	selectorKey struct{}
+)
+
+// WithPathRoot makes requests with ctx relative to root rather than the
+// client's PathRoot. Relative paths are still resolved against RootFolder,
+// inside root.
+func WithPathRoot(ctx context.Context, root PathRoot) context.Context {
+	return context.WithValue(ctx, pathRootKey{}, root)
+}
+
+// WithSelector makes requests with ctx act as sel rather than the client's
+// Selector.
+func WithSelector(ctx context.Context, sel Selector) context.Context {
+	return context.WithValue(ctx, selectorKey{}, sel)
+}
+
+// WithSelectUser is WithSelector(ctx, SelectUser(memberID)).
+func WithSelectUser(ctx context.Context, memberID string) context.Context {
+	return WithSelector(ctx, SelectUser(memberID))
+}
+
+// WithSelectAdmin is WithSelector(ctx, SelectAdmin(memberID)).
+func WithSelectAdmin(ctx context.Context, memberID string) context.Context {
+	return WithSelector(ctx, SelectAdmin(memberID))
+}
+
+// teamScope is the path root and team member a request's context selects, if
+// any, instead of the client's.
+type teamScope struct {
+	root PathRoot
+	sel  Selector
+}
+
+func scopeOf(ctx context.Context) teamScope {
+	var scope teamScope
+	scope.root, _ = ctx.Value(pathRootKey{}).(PathRoot)
+	scope.sel, _ = ctx.Value(selectorKey{}).(Selector)
+
+	return scope
+}
+
+// setTeamHeaders sets the path root and team member headers, preferring the
+// request context's to the client's.
+func (c *Client) setTeamHeaders(req *http.Request) error {
+	ctx := req.Context()
+
+	root := c.PathRoot
+	if r, ok := ctx.Value(pathRootKey{}).(PathRoot); ok {
+		root = &r
+	}
+	if root != nil {
+		b, err := json.Marshal(root)
+		if err != nil {
+			return fmt.Errorf("error encoding path root: %w", err)
+		}
+		req.Header.Set("Dropbox-API-Path-Root", string(b))
+	}
+
+	sel := c.Selector
+	if s, ok := ctx.Value(selectorKey{}).(Selector); ok {
+		sel = &s
+	}
+	switch {
+	case sel == nil || sel.MemberID == "":
+	case sel.Admin:
+		req.Header.Set("Dropbox-API-Select-Admin", sel.MemberID)
+	default:
+		req.Header.Set("Dropbox-API-Select-User", sel.MemberID)
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Middleware wraps the transport requests to Dropbox go through; see
+// Client.Use.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Use adds middleware to the client's transport. The first middleware added
+// sees requests first, and responses last.
+func (c *Client) Use(middleware ...Middleware) {
+	c.Middleware = append(c.Middleware, middleware...)
+}
+
+// httpClient returns base with its transport wrapped in the client's
+// middleware. Middleware wraps whatever authenticates base, so it doesn't see
+// the Authorization header that adds.
+func (c *Client) httpClient(base *http.Client) *http.Client {
+	if len(c.Middleware) == 0 {
+		return base
+	}
+
+	transport := base.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		transport = c.Middleware[i](transport)
+	}
+
+	wrapped := *base
+	wrapped.Transport = transport
+	return &wrapped
+}
+
+type requestIDKey struct{}
+
+// WithRequestID sets the ID RequestIDs gives requests made with ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID RequestIDs gave the request ctx belongs to.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDs gives every request an ID, unless its context already has one,
+// which middleware after it can find with RequestID. Retries are requests of
+// their own, so they get new IDs unless the caller set one with
+// WithRequestID.
+func RequestIDs() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if RequestID(req.Context()) != "" {
+				return next.RoundTrip(req)
+			}
+
+			b := make([]byte, 8)
+			rand.Read(b)
+
+			return next.RoundTrip(req.WithContext(WithRequestID(req.Context(), hex.EncodeToString(b))))
+		})
+	}
+}
+
+// LogRequests logs every request and its response at debug level, or failed
+// ones at warn level, with secrets redacted.
+func LogRequests(logger *slog.Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			prefix := "http"
+			if id := RequestID(req.Context()); id != "" {
+				prefix += " " + id
+			}
+
+			logger.Debug(fmt.Sprintf("%s: %s %s %v",
+				prefix, req.Method, redactURL(req.URL), redactHeader(req.Header),
+			))
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			elapsed := time.Since(start)
+
+			switch {
+			case err != nil:
+				logger.Warn(fmt.Sprintf("%s: %s %s failed after %s: %v",
+					prefix, req.Method, redactURL(req.URL), elapsed, err,
+				))
+			case resp.StatusCode >= 400:
+				msg := fmt.Sprintf("%s: %s %s responded %s after %s",
+					prefix, req.Method, redactURL(req.URL), resp.Status, elapsed,
+				)
+				if id := resp.Header.Get("X-Dropbox-Request-Id"); id != "" {
+					msg += " (dropbox request " + id + ")"
+				}
+				logger.Warn(msg)
+			default:
+				logger.Debug(fmt.Sprintf("%s: %s %s responded %s after %s %v",
+					prefix, req.Method, redactURL(req.URL), resp.Status, elapsed, resp.Header,
+				))
+			}
+
+			return resp, err
+		})
+	}
+}
+
+// MeasureRequests records the latency and outcome of requests, per endpoint,
+// in metrics. Responses with error statuses count as errors.
+func MeasureRequests(metrics *Metrics) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			observed := err
+			if err == nil && resp.StatusCode >= 400 {
+				observed = errors.New(resp.Status)
+			}
+			metrics.Observe(endpoint(req.URL), time.Since(start), observed)
+
+			return resp, err
+		})
+	}
+}
+
+// RecordRequests writes every request and its response, bodies included and
+// secrets redacted, to a file in dir. Bodies are held in memory to be
+// recorded, so it's meant for debugging rather than production.
+func RecordRequests(dir string) Middleware {
+	var count atomic.Int64
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			name := fmt.Sprintf("%s-%04d%s.http",
+				time.Now().UTC().Format("20060102T150405"),
+				count.Add(1),
+				strings.ReplaceAll(endpoint(req.URL), "/", "_"),
+			)
+
+			var reqBody []byte
+			if req.Body != nil && req.Body != http.NoBody {
+				var err error
+				if reqBody, err = io.ReadAll(req.Body); err != nil {
+					return nil, fmt.Errorf("error recording request body: %w", err)
+				}
+				req.Body.Close()
+
+				req = req.Clone(req.Context())
+				req.Body = io.NopCloser(bytes.NewReader(reqBody))
+			}
+
+			resp, err := next.RoundTrip(req)
+
+			record := &bytes.Buffer{}
+			recorded := req.Clone(req.Context())
+			recorded.URL, _ = url.Parse(redactURL(req.URL))
+			recorded.Header = redactHeader(req.Header)
+			if dump, dumpErr := httputil.DumpRequest(recorded, false); dumpErr == nil {
+				record.Write(dump)
+			}
+			record.Write(reqBody)
+			record.WriteString("\n\n")
+
+			if err != nil {
+				fmt.Fprintf(record, "error: %v\n", err)
+			} else {
+				respBody, readErr := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				resp.Body = io.NopCloser(bytes.NewReader(respBody))
+				if readErr != nil {
+					return nil, fmt.Errorf("error recording response body: %w", readErr)
+				}
+
+				if dump, dumpErr := httputil.DumpResponse(resp, false); dumpErr == nil {
+					record.Write(dump)
+				}
+				record.Write(respBody)
+				record.WriteString("\n")
+			}
+
+			if writeErr := os.WriteFile(filepath.Join(dir, name), record.Bytes(), 0o600); writeErr != nil {
+				return nil, fmt.Errorf("error recording request: %w", writeErr)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+const redacted = "REDACTED"
+
+// redactURL hides the argument content endpoints take in the URL.
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	if !query.Has("arg") {
+		return u.String()
+	}
+	query.Set("arg", redacted)
+
+	r := *u
+	r.RawQuery = query.Encode()
+	return r.String()
+}
+
+// redactHeader hides the access token and the argument content endpoints can
+// take in a header. Client's own middleware runs before its HTTP client adds
+// the token, but LogRequests and RecordRequests can also run inside a
+// transport that already has.
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range []string{"Authorization", "Dropbox-API-Arg"} {
+		if h.Get(name) != "" {
+			h.Set(name, redacted)
+		}
+	}
+
+	return h
+}
+
+// endpoint is the API endpoint a URL is for, e.g. "/files/download".
+func endpoint(u *url.URL) string {
+	if i := strings.Index(u.Path, "/2/"); i >= 0 {
+		return u.Path[i+2:]
+	}
+
+	return u.Path
+}
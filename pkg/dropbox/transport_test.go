@@ -0,0 +1,57 @@
+package dropbox_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox"
+	"github.com/ice-cream-psychics-club/dropbox/pkg/dropbox/dropboxtest"
+)
+
+func TestMiddlewareRedactsSecrets(t *testing.T) {
+	server := dropboxtest.NewServer()
+	defer server.Close()
+
+	logs := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	dir := t.TempDir()
+	client := &http.Client{
+		Transport: dropbox.LogRequests(logger)(dropbox.RecordRequests(dir)(server.Server.Client().Transport)),
+	}
+
+	req, err := http.NewRequest("POST", server.URL+"/2/users/get_current_account", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as it is when the middleware runs inside a transport that adds the token
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Dropbox-API-Arg", `{"secret":"secret-arg"}`)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	recorded, err := filepath.Glob(filepath.Join(dir, "*.http"))
+	if err != nil || len(recorded) != 1 {
+		t.Fatalf("got recordings %v (%v), want one", recorded, err)
+	}
+	record, err := os.ReadFile(recorded[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, out := range map[string]string{"log": logs.String(), "recording": string(record)} {
+		for _, secret := range []string{"secret-token", "secret-arg"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("%s contains %s:\n%s", name, secret, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	DefaultUploadChunkSize int64 = 8 << 20
+	// Dropbox rejects single requests over 150 MiB, so anything larger than
+	// this goes through an upload session
+	DefaultUploadSessionThreshold int64 = 32 << 20
+
+	maxUploadRequestSize int64 = 150 << 20
+)
+
+type (
+	uploadSessionCursor struct {
+		SessionID string `json:"session_id"`
+		Offset    int64  `json:"offset"`
+	}
+
+	uploadSessionLookupError struct {
+		Tag           string `json:".tag"`
+		CorrectOffset int64  `json:"correct_offset"`
+	}
+)
+
+func (c *Client) uploadChunkSize() int64 {
+	if c.UploadChunkSize <= 0 {
+		return DefaultUploadChunkSize
+	}
+
+	return min(c.UploadChunkSize, maxUploadRequestSize)
+}
+
+func (c *Client) uploadSessionThreshold() int64 {
+	if c.UploadSessionThreshold <= 0 {
+		return DefaultUploadSessionThreshold
+	}
+
+	return min(c.UploadSessionThreshold, maxUploadRequestSize)
+}
+
+// uploadSession streams r to filePath in chunks of UploadChunkSize using
+// /files/upload_session/start, append_v2 and finish.
+func (c *Client) uploadSession(ctx context.Context, filePath string, r io.Reader, opts UploadOptions) (*FileMetadata, error) {
+	chunk := make([]byte, c.uploadChunkSize())
+
+	// start the session with the first chunk
+	n, err := io.ReadFull(r, chunk)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error reading upload body: %w", err)
+	}
+
+	urlPath := "/files/upload_session/start"
+	req, err := c.newContentRequest(ctx, urlPath, map[string]any{"close": false}, bytes.NewReader(chunk[:n]))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	c.Logger.Debug("client.Upload: " + redactURL(req.URL))
+
+	var session struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := c.doRequest(req, urlPath, &session); err != nil {
+		return nil, fmt.Errorf("error starting upload session for %s: %w", filePath, err)
+	}
+
+	cursor := uploadSessionCursor{
+		SessionID: session.SessionID,
+		Offset:    int64(n),
+	}
+
+	// append the rest, keeping the final chunk for finish
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("error reading upload body at offset %d: %w", cursor.Offset, err)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return c.finishUploadSession(ctx, filePath, cursor, chunk[:n], opts)
+		}
+
+		if err := c.appendUploadSession(ctx, &cursor, chunk[:n]); err != nil {
+			return nil, fmt.Errorf("error uploading %s: %w", filePath, err)
+		}
+	}
+
+	return c.finishUploadSession(ctx, filePath, cursor, nil, opts)
+}
+
+// appendUploadSession appends chunk at cursor's offset. Failed appends are
+// replayed like any idempotent request; when a replay finds that the session
+// already has part of the chunk, from an attempt whose response was lost, the
+// remainder of the chunk is sent from the acknowledged offset.
+func (c *Client) appendUploadSession(ctx context.Context, cursor *uploadSessionCursor, chunk []byte) error {
+	urlPath := "/files/upload_session/append_v2"
+	start := cursor.Offset
+	end := start + int64(len(chunk))
+
+	for {
+		params := map[string]any{
+			"cursor": cursor,
+			"close":  false,
+		}
+
+		req, err := c.newContentRequest(ctx, urlPath, params, bytes.NewReader(chunk[cursor.Offset-start:]))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		c.Logger.Debug(fmt.Sprintf("client.Upload: append %s @ %d", cursor.SessionID, cursor.Offset))
+
+		resp, err := c.send(req, urlPath)
+		if err == nil {
+			resp.Body.Close()
+			cursor.Offset = end
+			return nil
+		}
+
+		// resume from wherever Dropbox says the session is at, as long as
+		// that's further into the chunk
+		offset, ok := correctOffset(err)
+		if !ok || offset <= cursor.Offset || offset > end {
+			return fmt.Errorf("error appending to upload session %s @ %d: %w", cursor.SessionID, cursor.Offset, err)
+		}
+
+		c.Logger.Warn(fmt.Sprintf("client.Upload: resuming append %s from acknowledged offset %d", cursor.SessionID, offset))
+		cursor.Offset = offset
+		if offset == end {
+			return nil
+		}
+	}
+}
+
+func (c *Client) finishUploadSession(ctx context.Context, filePath string, cursor uploadSessionCursor, chunk []byte, opts UploadOptions) (*FileMetadata, error) {
+	params := map[string]any{
+		"cursor": cursor,
+		"commit": opts.commitInfo(filePath),
+	}
+
+	urlPath := "/files/upload_session/finish"
+	req, err := c.newContentRequest(ctx, urlPath, params, bytes.NewReader(chunk))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	c.Logger.Debug("client.Upload: " + redactURL(req.URL))
+
+	var file FileMetadata
+	if err := c.doRequest(req, urlPath, &file); err != nil {
+		return nil, uploadErr(filePath, err)
+	}
+
+	return &file, nil
+}
+
+func correctOffset(err error) (int64, bool) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || !apiErr.HasTag("incorrect_offset") {
+		return 0, false
+	}
+
+	var lookupErr uploadSessionLookupError
+	if err := json.Unmarshal(apiErr.Detail, &lookupErr); err != nil {
+		return 0, false
+	}
+
+	return lookupErr.CorrectOffset, true
+}
@@ -0,0 +1,97 @@
+package dropbox
+
+import (
+	"context"
+)
+
+type (
+	FullAccount struct {
+		AccountID string `json:"account_id"`
+		Name      struct {
+			DisplayName  string `json:"display_name"`
+			GivenName    string `json:"given_name"`
+			Surname      string `json:"surname"`
+			FamiliarName string `json:"familiar_name"`
+		} `json:"name"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Disabled      bool   `json:"disabled"`
+		Country       string `json:"country,omitempty"`
+		Locale        string `json:"locale"`
+		AccountType   struct {
+			// Tag is one of "basic", "pro" or "business"
+			Tag string `json:".tag"`
+		} `json:"account_type"`
+		RootInfo RootInfo `json:"root_info"`
+	}
+
+	// RootInfo identifies the namespaces paths are resolved against; they
+	// differ for team members.
+	RootInfo struct {
+		Tag             string `json:".tag"`
+		RootNamespaceID string `json:"root_namespace_id"`
+		HomeNamespaceID string `json:"home_namespace_id"`
+	}
+
+	SpaceUsage struct {
+		Used       uint64          `json:"used"`
+		Allocation SpaceAllocation `json:"allocation"`
+	}
+
+	SpaceAllocation struct {
+		// Tag is "individual" or, for space shared with a team, "team"
+		Tag       string `json:".tag"`
+		Allocated uint64 `json:"allocated"`
+		// Used is the whole team's usage
+		Used uint64 `json:"used,omitempty"`
+		// UserWithinTeamSpaceAllocated is the member's own limit within the
+		// team's space; zero is unlimited
+		UserWithinTeamSpaceAllocated uint64 `json:"user_within_team_space_allocated,omitempty"`
+	}
+)
+
+// UsedFraction is how much of the space available to the account is used,
+// between 0 and 1. It's 0 when the allocation is unknown.
+func (u *SpaceUsage) UsedFraction() float64 {
+	used, allocated := u.Used, u.Allocation.Allocated
+	if u.Allocation.Tag == "team" {
+		if u.Allocation.UserWithinTeamSpaceAllocated != 0 {
+			allocated = u.Allocation.UserWithinTeamSpaceAllocated
+		} else {
+			used = u.Allocation.Used
+		}
+	}
+
+	if allocated == 0 {
+		return 0
+	}
+
+	return float64(used) / float64(allocated)
+}
+
+// GetCurrentAccount describes the account the client is authorized for.
+func (c *Client) GetCurrentAccount() (*FullAccount, error) {
+	return c.GetCurrentAccountContext(context.Background())
+}
+
+func (c *Client) GetCurrentAccountContext(ctx context.Context) (*FullAccount, error) {
+	var account FullAccount
+	if err := c.rpc(ctx, "/users/get_current_account", nil, &account); err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (c *Client) GetSpaceUsage() (*SpaceUsage, error) {
+	return c.GetSpaceUsageContext(context.Background())
+}
+
+func (c *Client) GetSpaceUsageContext(ctx context.Context) (*SpaceUsage, error) {
+	var usage SpaceUsage
+	if err := c.rpc(ctx, "/users/get_space_usage", nil, &usage); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
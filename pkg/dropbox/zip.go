@@ -0,0 +1,241 @@
+package dropbox
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+const DefaultZipConcurrency = 4
+
+// DownloadZip streams the folder to w as a zip archive. Folders over the
+// server's limits are built client-side with BuildZip instead.
+func (c *Client) DownloadZip(folderPath string, w io.Writer) error {
+	return c.DownloadZipContext(context.Background(), folderPath, w)
+}
+
+func (c *Client) DownloadZipContext(ctx context.Context, folderPath string, w io.Writer) error {
+	folderPath = c.ResolvePath(folderPath)
+
+	params := map[string]any{
+		"path": folderPath,
+	}
+
+	urlPath := "/files/download_zip"
+	req, err := c.newContentRequest(ctx, urlPath, params, nil)
+	if err != nil {
+		return err
+	}
+	c.Logger.Debug("client.DownloadZip: " + redactURL(req.URL))
+
+	resp, err := c.send(req, urlPath)
+	if errors.Is(err, ErrZipTooLarge) {
+		c.Logger.Info("client.DownloadZip: " + folderPath + " is too large to zip on the server, building it here")
+		return c.BuildZipContext(ctx, folderPath, w)
+	}
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %w", folderPath, err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("error streaming %s: %w", folderPath, err)
+	}
+
+	return nil
+}
+
+// BuildZip writes the folder to w as a zip archive laid out like DownloadZip's,
+// downloading up to ZipConcurrency files at once. Files that can't be
+// downloaded, like Google Docs, are left out.
+func (c *Client) BuildZip(folderPath string, w io.Writer) error {
+	return c.BuildZipContext(context.Background(), folderPath, w)
+}
+
+func (c *Client) BuildZipContext(ctx context.Context, folderPath string, w io.Writer) error {
+	folderPath = c.ResolvePath(folderPath)
+
+	folder, err := c.ListFolderAllContext(ctx, folderPath, "")
+	if err != nil {
+		return fmt.Errorf("error listing %s: %w", folderPath, err)
+	}
+
+	archive := &zipArchive{
+		w:      zip.NewWriter(w),
+		folder: strings.ToLower(folderPath),
+		root:   path.Base(folderPath),
+	}
+	if folderPath == "" {
+		archive.root = ""
+	}
+
+	var files []*FileMetadata
+	for _, entry := range folder.Entries {
+		switch e := entry.(type) {
+		case *FolderMetadata:
+			if e.PathLower == archive.folder {
+				// use the folder's own spelling
+				archive.root = e.Name
+			}
+			name := archive.name(e)
+			if name == "" {
+				// the account's root
+				continue
+			}
+			if _, err := archive.w.CreateHeader(&zip.FileHeader{Name: name + "/"}); err != nil {
+				return fmt.Errorf("error adding %s: %w", e.PathDisplay, err)
+			}
+		case *FileMetadata:
+			if !e.IsDownloadable {
+				c.Logger.Warn("client.BuildZip: skipping " + e.PathDisplay + ", it can't be downloaded")
+				continue
+			}
+			files = append(files, e)
+			archive.totalBytes += int64(e.Size)
+		}
+	}
+	archive.totalFiles = len(files)
+
+	concurrency := c.ZipConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultZipConcurrency
+	}
+
+	// download files concurrently and add them to the archive one at a time
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		slots    = make(chan struct{}, concurrency)
+	)
+	for _, f := range files {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := c.addToZip(ctx, archive, f); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := archive.w.Close(); err != nil {
+		return fmt.Errorf("error finishing archive: %w", err)
+	}
+
+	c.Logger.Info(fmt.Sprintf("client.BuildZip: zipped %d files (%d bytes) from %s",
+		archive.totalFiles, archive.totalBytes, folderPath,
+	))
+	return nil
+}
+
+// addToZip downloads the file to a temporary file, so other downloads can
+// carry on while it waits its turn to be written to the archive.
+func (c *Client) addToZip(ctx context.Context, archive *zipArchive, f *FileMetadata) error {
+	result, err := c.DownloadContext(ctx, f.PathLower)
+	if err != nil {
+		return fmt.Errorf("error downloading %s: %w", f.PathDisplay, err)
+	}
+	defer result.Body.Close()
+
+	tmp, err := os.CreateTemp("", "dropbox-zip-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, result.Body); err != nil {
+		return fmt.Errorf("error downloading %s: %w", f.PathDisplay, err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error rewinding %s: %w", tmp.Name(), err)
+	}
+
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+
+	zf, err := archive.w.CreateHeader(&zip.FileHeader{
+		Name:     archive.name(f),
+		Method:   zip.Deflate,
+		Modified: result.File.ClientModified,
+	})
+	if err != nil {
+		return fmt.Errorf("error adding %s: %w", f.PathDisplay, err)
+	}
+	if _, err := io.Copy(zf, tmp); err != nil {
+		return fmt.Errorf("error adding %s: %w", f.PathDisplay, err)
+	}
+
+	archive.doneFiles++
+	archive.doneBytes += int64(result.File.Size)
+	c.Logger.Debug("client.BuildZip: added " + f.PathDisplay)
+
+	// report progress every tenth of the way
+	if step := archive.doneFiles * 10 / archive.totalFiles; step > archive.reported {
+		archive.reported = step
+		c.Logger.Info(fmt.Sprintf("client.BuildZip: %d of %d files, %d of %d bytes",
+			archive.doneFiles, archive.totalFiles, archive.doneBytes, archive.totalBytes,
+		))
+	}
+
+	return nil
+}
+
+type zipArchive struct {
+	w *zip.Writer
+	// folder is the lower-cased path being zipped, and root the directory
+	// its entries are put in
+	folder string
+	root   string
+
+	// guards w and progress
+	mu         sync.Mutex
+	totalFiles int
+	totalBytes int64
+	doneFiles  int
+	doneBytes  int64
+	reported   int
+}
+
+// name is the entry's path in the archive, keeping its display casing where
+// it can.
+func (a *zipArchive) name(m Metadata) string {
+	display, lower := m.Base().PathDisplay, m.Base().PathLower
+
+	rel := strings.TrimPrefix(lower, a.folder)
+	if len(display) == len(lower) && strings.EqualFold(display[:len(a.folder)], a.folder) {
+		rel = display[len(a.folder):]
+	}
+
+	return strings.TrimPrefix(path.Join(a.root, rel), "/")
+}
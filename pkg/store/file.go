@@ -0,0 +1,102 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore is a store that's saved to a JSON file whenever it changes, so
+// its values outlive the process.
+type FileStore struct {
+	path   string
+	values map[string]string
+	sync.RWMutex
+}
+
+// NewFileStore loads the store saved at path, or starts an empty one if
+// there's nothing there yet.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path:   path,
+		values: make(map[string]string),
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(b, &s.values); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Set(key, value string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.values[key] = value
+	return s.save()
+}
+
+func (s *FileStore) Get(key string) (string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+func (s *FileStore) Delete(key string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.values[key]; !ok {
+		return false, nil
+	}
+	delete(s.values, key)
+
+	return true, s.save()
+}
+
+// save replaces the file in one step, so it's never left half written.
+func (s *FileStore) save() error {
+	b, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error saving store: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("error saving store: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error saving store: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		return fmt.Errorf("error saving store: %w", err)
+	}
+
+	return nil
+}